@@ -0,0 +1,317 @@
+// Package cache keeps a local copy of memo listings so get-memos can answer
+// without paging through the whole server on every run.
+//
+// Syncs ask the server for the most recently updated memos first. Once a
+// full sync has shown that the server honors that order, by checking that
+// asking for the reverse order starts with the other end of the listing,
+// an incremental
+// sync reads pages only until it reaches memos updated before the newest
+// cached update, so new memos and edits to memos of any age are picked up
+// cheaply. Servers that ignore the order get a full sync every time.
+// Deletions, archived memos and memos no longer matching the filter are
+// only seen by a full sync, which happens when asked for and at least
+// every FullSyncInterval. Cached memos are kept in the server's default
+// list order.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"memo/client"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// FullSyncInterval is the longest time between two full syncs.
+const FullSyncInterval = 24 * time.Hour
+
+// syncPageSize is the page size used while syncing.
+const syncPageSize = 50
+
+// Cache is the cached listing of one server and filter.
+type Cache struct {
+	BaseURL    string    `json:"baseUrl"`
+	Filter     string    `json:"filter"`
+	SyncedAt   time.Time `json:"syncedAt"`
+	FullSyncAt time.Time `json:"fullSyncAt"`
+	// UpdateOrder is set once the server has listed memos in update order,
+	// which incremental syncs rely on.
+	UpdateOrder bool          `json:"updateOrder"`
+	Memos       []client.Memo `json:"memos"`
+
+	path string
+}
+
+// SyncStats reports what a sync changed.
+type SyncStats struct {
+	Full    bool
+	Added   int
+	Updated int
+	Removed int
+}
+
+// Dir returns the directory holding the cache files.
+func Dir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locating cache directory: %w", err)
+	}
+	return filepath.Join(base, "memo"), nil
+}
+
+// Load reads the cache for a server and filter. A missing cache file gives
+// an empty cache that has never been synced.
+func Load(baseURL, filter string) (*Cache, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(baseURL + "\n" + filter))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+
+	c := &Cache{BaseURL: baseURL, Filter: filter, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		// A corrupt cache is rebuilt by the next sync
+		return &Cache{BaseURL: baseURL, Filter: filter, path: path}, nil
+	}
+	return c, nil
+}
+
+// Save writes the cache file atomically.
+func (c *Cache) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encoding cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".memos-*.json")
+	if err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	return nil
+}
+
+// Incremental reports whether a Sync that is not asked to be full reads
+// only the memos updated since the last one.
+func (c *Cache) Incremental() bool {
+	return c.UpdateOrder && !c.FullSyncAt.IsZero() && time.Since(c.FullSyncAt) <= FullSyncInterval
+}
+
+// Sync brings the cache up to date with the server. It does a full sync when
+// full is set, the cache is empty, the last full sync is too old or the
+// server has not shown that it lists memos in update order, and an
+// incremental one otherwise. The cache is not saved.
+func (c *Cache) Sync(ctx context.Context, cl *client.Client, full bool) (SyncStats, error) {
+	now := time.Now()
+	if !full && c.Incremental() {
+		stats, ok, err := c.syncUpdated(ctx, cl)
+		if err != nil {
+			return SyncStats{}, err
+		}
+		if ok {
+			c.SyncedAt = now
+			return stats, nil
+		}
+		// The server stopped listing in update order, read everything
+	}
+
+	memos, err := cl.ListUpTo(ctx, client.ListMemosRequest{Filter: c.Filter, PageSize: syncPageSize, OrderBy: client.OrderByUpdateTime}, 0)
+	if err != nil {
+		return SyncStats{}, err
+	}
+	stats := c.diff(memos)
+	stats.Full = true
+	if c.UpdateOrder, err = updateOrdered(ctx, cl, c.Filter, memos); err != nil {
+		return SyncStats{}, err
+	}
+	c.Memos = memos
+	sortListOrder(c.Memos)
+	c.SyncedAt, c.FullSyncAt = now, now
+	return stats, nil
+}
+
+// syncUpdated lists memos most recently updated first and merges those
+// updated since the newest cached update. It reports false, changing
+// nothing, when the listing turns out not to be in update order.
+func (c *Cache) syncUpdated(ctx context.Context, cl *client.Client) (SyncStats, bool, error) {
+	var newest time.Time
+	cached := make(map[string]time.Time, len(c.Memos))
+	for _, m := range c.Memos {
+		cached[m.Name] = m.UpdateTime
+		if m.UpdateTime.After(newest) {
+			newest = m.UpdateTime
+		}
+	}
+
+	var changed []client.Memo
+	var stats SyncStats
+	var previous time.Time
+	req := client.ListMemosRequest{Filter: c.Filter, PageSize: syncPageSize, OrderBy: client.OrderByUpdateTime}
+pages:
+	for {
+		page, err := cl.List(ctx, req)
+		if err != nil {
+			return SyncStats{}, false, err
+		}
+
+		for _, m := range page.Memos {
+			if !previous.IsZero() && m.UpdateTime.After(previous) {
+				return SyncStats{}, false, nil
+			}
+			previous = m.UpdateTime
+			// Memos updated before the newest cached update are cached
+			if m.UpdateTime.Before(newest) {
+				break pages
+			}
+			updated, ok := cached[m.Name]
+			switch {
+			case !ok:
+				stats.Added++
+			case !m.UpdateTime.Equal(updated):
+				stats.Updated++
+			default:
+				continue
+			}
+			changed = append(changed, m)
+		}
+
+		if page.NextPageToken == "" {
+			break
+		}
+		req.PageToken = page.NextPageToken
+	}
+
+	names := make(map[string]bool, len(changed))
+	for _, m := range changed {
+		names[m.Name] = true
+	}
+	c.Memos = append(slices.DeleteFunc(c.Memos, func(m client.Memo) bool {
+		return names[m.Name]
+	}), changed...)
+	sortListOrder(c.Memos)
+	return stats, true, nil
+}
+
+// updateOrdered reports whether the full listing memos, asked for most
+// recently updated first, shows that the server sorts by update time. The
+// update times must never increase, and asking for the least recently
+// updated memo must give the last memo of the listing rather than the first:
+// an old server ignoring the order would list both in its default order.
+// Listings of fewer than two memos prove nothing.
+func updateOrdered(ctx context.Context, cl *client.Client, filter string, memos []client.Memo) (bool, error) {
+	if len(memos) < 2 {
+		return false, nil
+	}
+	for i := 1; i < len(memos); i++ {
+		if memos[i].UpdateTime.After(memos[i-1].UpdateTime) {
+			return false, nil
+		}
+	}
+	page, err := cl.List(ctx, client.ListMemosRequest{Filter: filter, PageSize: 1, OrderBy: client.OrderByUpdateTimeAsc})
+	if err != nil {
+		return false, err
+	}
+	if len(page.Memos) == 0 {
+		return false, nil
+	}
+	first := page.Memos[0].Name
+	return first == memos[len(memos)-1].Name && first != memos[0].Name, nil
+}
+
+// sortListOrder sorts memos the way the server lists them by default:
+// pinned memos first, then by display time, newest first.
+func sortListOrder(memos []client.Memo) {
+	slices.SortStableFunc(memos, func(a, b client.Memo) int {
+		if a.Pinned != b.Pinned {
+			if a.Pinned {
+				return -1
+			}
+			return 1
+		}
+		return b.DisplayTime.Compare(a.DisplayTime)
+	})
+}
+
+// diff counts how a full listing differs from the cached memos.
+func (c *Cache) diff(memos []client.Memo) SyncStats {
+	cached := make(map[string]time.Time, len(c.Memos))
+	for _, m := range c.Memos {
+		cached[m.Name] = m.UpdateTime
+	}
+
+	var stats SyncStats
+	for _, m := range memos {
+		updated, ok := cached[m.Name]
+		switch {
+		case !ok:
+			stats.Added++
+		case !m.UpdateTime.Equal(updated):
+			stats.Updated++
+		}
+		delete(cached, m.Name)
+	}
+	stats.Removed = len(cached)
+	return stats
+}
+
+// Forget removes a memo from every cache file, e.g. after deleting it.
+func Forget(name string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("listing cache files: %w", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading cache: %w", err)
+		}
+		c := &Cache{path: path}
+		if err := json.Unmarshal(data, c); err != nil {
+			continue
+		}
+		n := len(c.Memos)
+		c.Memos = slices.DeleteFunc(c.Memos, func(m client.Memo) bool {
+			return m.Name == name
+		})
+		if len(c.Memos) == n {
+			continue
+		}
+		if err := c.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"memo/client"
+	"memo/memotest"
+	"net/http"
+	"testing"
+)
+
+func newTestCache(t *testing.T, memos int) (*memotest.Server, *client.Client, *Cache) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	srv := memotest.NewServer("token")
+	t.Cleanup(srv.Close)
+	for i := 0; i < memos; i++ {
+		srv.AddMemo(fmt.Sprintf("```shell\necho %d\n```\n\n**Tags:**\n#cmd", i), "")
+	}
+	cl := client.New(srv.URL, "token")
+
+	c, err := Load(cl.BaseURL, "tag_search == ['cmd']")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return srv, cl, c
+}
+
+// editMemo appends text to a memo on the server.
+func editMemo(t *testing.T, cl *client.Client, name, text string) client.Memo {
+	t.Helper()
+	ctx := context.Background()
+	memo, err := cl.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	memo.Content += text
+	updated, err := cl.Update(ctx, memo, "content")
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	return *updated
+}
+
+func TestSync(t *testing.T) {
+	srv, cl, c := newTestCache(t, 120)
+	ctx := context.Background()
+
+	stats, err := c.Sync(ctx, cl, false)
+	if err != nil {
+		t.Fatalf("first Sync: %v", err)
+	}
+	if !stats.Full || stats.Added != 120 || len(c.Memos) != 120 || !c.UpdateOrder {
+		t.Fatalf("first sync: stats %+v with %d memos, update order %v, want a full sync adding 120 in update order",
+			stats, len(c.Memos), c.UpdateOrder)
+	}
+	if c.Memos[0].Name != "memos/120" || c.Memos[119].Name != "memos/1" {
+		t.Errorf("cached memos run from %s to %s, want the list order from memos/120 to memos/1", c.Memos[0].Name, c.Memos[119].Name)
+	}
+
+	// A new memo is picked up from the first page alone
+	before := len(srv.Requests())
+	added := srv.AddMemo("```shell\nuptime\n```\n\n**Tags:**\n#cmd", "")
+	stats, err = c.Sync(ctx, cl, false)
+	if err != nil {
+		t.Fatalf("incremental Sync: %v", err)
+	}
+	if stats.Full || stats.Added != 1 || stats.Updated != 0 {
+		t.Errorf("incremental sync stats = %+v, want 1 added", stats)
+	}
+	if len(c.Memos) != 121 || c.Memos[0].Name != added.Name {
+		t.Errorf("got %d memos starting with %s, want 121 starting with %s", len(c.Memos), c.Memos[0].Name, added.Name)
+	}
+	if requests := len(srv.Requests()) - before; requests != 1 {
+		t.Errorf("incremental sync made %d requests, want 1", requests)
+	}
+
+	// A full sync notices deletions
+	if err := cl.Delete(ctx, added.Name); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	stats, err = c.Sync(ctx, cl, true)
+	if err != nil {
+		t.Fatalf("full Sync: %v", err)
+	}
+	if !stats.Full || stats.Removed != 1 || len(c.Memos) != 120 {
+		t.Errorf("full sync: stats %+v with %d memos, want 1 removed leaving 120", stats, len(c.Memos))
+	}
+}
+
+func TestSyncDetectsOldEdit(t *testing.T) {
+	srv, cl, c := newTestCache(t, 120)
+	ctx := context.Background()
+	if _, err := c.Sync(ctx, cl, false); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// memos/5 is on the last page of the default list order
+	edited := editMemo(t, cl, "memos/5", " #edited")
+	before := len(srv.Requests())
+	stats, err := c.Sync(ctx, cl, false)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if stats.Full || stats.Updated != 1 || stats.Added != 0 {
+		t.Errorf("stats = %+v, want an incremental sync with 1 updated", stats)
+	}
+	if requests := len(srv.Requests()) - before; requests != 1 {
+		t.Errorf("incremental sync made %d requests, want 1", requests)
+	}
+	if len(c.Memos) != 120 {
+		t.Fatalf("got %d memos, want 120", len(c.Memos))
+	}
+	// The edit keeps its place in the list order
+	if got := c.Memos[115]; got.Name != edited.Name || got.Content != edited.Content {
+		t.Errorf("memo at position 115 = %s %q, want the edited %s", got.Name, got.Content, edited.Name)
+	}
+}
+
+func TestSyncWithoutUpdateOrder(t *testing.T) {
+	srv, cl, c := newTestCache(t, 120)
+	ctx := context.Background()
+	// An older server ignores orderBy and lists in its default order
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		query := r.URL.Query()
+		query.Del("orderBy")
+		r.URL.RawQuery = query.Encode()
+		return false
+	}
+	if _, err := c.Sync(ctx, cl, false); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if c.UpdateOrder {
+		t.Fatal("default list order taken for update order")
+	}
+
+	edited := editMemo(t, cl, "memos/5", " #edited")
+	stats, err := c.Sync(ctx, cl, false)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if !stats.Full || stats.Updated != 1 {
+		t.Errorf("stats = %+v, want a full sync with 1 updated", stats)
+	}
+	if got := c.Memos[115]; got.Content != edited.Content {
+		t.Errorf("memo %s not updated: %q", got.Name, got.Content)
+	}
+}
+
+func TestSaveLoadForget(t *testing.T) {
+	_, cl, c := newTestCache(t, 3)
+	if _, err := c.Sync(context.Background(), cl, false); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := Load(c.BaseURL, c.Filter)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded.Memos) != 3 || !loaded.SyncedAt.Equal(c.SyncedAt) {
+		t.Fatalf("loaded %d memos synced at %v, want 3 synced at %v", len(loaded.Memos), loaded.SyncedAt, c.SyncedAt)
+	}
+
+	other, err := Load(c.BaseURL, "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(other.Memos) != 0 || !other.SyncedAt.IsZero() {
+		t.Errorf("cache for another filter is not empty")
+	}
+
+	if err := Forget(c.Memos[0].Name); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	loaded, err = Load(c.BaseURL, c.Filter)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded.Memos) != 2 {
+		t.Errorf("after Forget got %d memos, want 2", len(loaded.Memos))
+	}
+}
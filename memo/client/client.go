@@ -0,0 +1,113 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds every request made by a Client created with New.
+const DefaultTimeout = 30 * time.Second
+
+// Client talks to the Memos v1 API.
+type Client struct {
+	// BaseURL is the server root, e.g. https://memos.example.com.
+	BaseURL string
+	// Token is the access token sent as a bearer token.
+	Token string
+	// TokenSource, when Token is empty, is called once on the first request
+	// to get the token, so secrets are only fetched when the server is used.
+	TokenSource func() (string, error)
+	// HTTPClient performs the requests.
+	HTTPClient *http.Client
+
+	tokenMu  sync.Mutex
+	tokenErr error
+}
+
+// New returns a client for the server at apiURL. The URL may be the server
+// root or already end with /api/v1/memos, as the sunbeam preferences allow.
+func New(apiURL, token string) *Client {
+	baseURL := strings.TrimRight(apiURL, "/")
+	baseURL = strings.TrimSuffix(baseURL, "/api/v1/memos")
+	baseURL = strings.TrimSuffix(baseURL, "/api/v1")
+	return &Client{
+		BaseURL:    baseURL,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// token returns the access token, resolving TokenSource on first use.
+func (c *Client) token() (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.Token == "" && c.TokenSource != nil && c.tokenErr == nil {
+		c.Token, c.tokenErr = c.TokenSource()
+		if c.tokenErr != nil {
+			c.tokenErr = fmt.Errorf("memos: getting access token: %w", c.tokenErr)
+		}
+	}
+	return c.Token, c.tokenErr
+}
+
+// do sends a request to /api/v1/{path} and decodes a JSON response into out.
+// Non-2xx responses are returned as *APIError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	endpoint := c.BaseURL + "/api/v1/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("memos: encoding %s request: %w", path, err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("memos: creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("memos: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("memos: reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, data)
+	}
+
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return &DecodeError{Path: path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"memo/client"
+	"memo/memotest"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const token = "test-token"
+
+func newTestClient(t *testing.T) (*memotest.Server, *client.Client) {
+	t.Helper()
+	srv := memotest.NewServer(token)
+	t.Cleanup(srv.Close)
+	return srv, client.New(srv.URL, token)
+}
+
+// countRequests counts the requests made to the given method and path.
+func countRequests(srv *memotest.Server, method, path string) int {
+	n := 0
+	for _, r := range srv.Requests() {
+		uri, _, _ := strings.Cut(r, "?")
+		if uri == method+" "+path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNewTrimsAPIPath(t *testing.T) {
+	tests := []struct {
+		apiURL string
+		want   string
+	}{
+		{"https://memos.example.com", "https://memos.example.com"},
+		{"https://memos.example.com/", "https://memos.example.com"},
+		{"https://memos.example.com/api/v1/memos", "https://memos.example.com"},
+		{"https://memos.example.com/api/v1/memos/", "https://memos.example.com"},
+		{"https://example.com/memos/api/v1", "https://example.com/memos"},
+	}
+	for _, tt := range tests {
+		if got := client.New(tt.apiURL, token).BaseURL; got != tt.want {
+			t.Errorf("New(%q).BaseURL = %q, want %q", tt.apiURL, got, tt.want)
+		}
+	}
+}
+
+func TestListAllPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		memos     int
+		wantPages int
+	}{
+		{"empty", 0, 1},
+		{"single page", 3, 1},
+		{"exactly one page", memotest.DefaultPageSize, 1},
+		{"one over a page", memotest.DefaultPageSize + 1, 2},
+		{"several pages", 3*memotest.DefaultPageSize + 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, c := newTestClient(t)
+			for i := 0; i < tt.memos; i++ {
+				srv.AddMemo(fmt.Sprintf("```shell\necho %d\n```\n\n**Tags:**\n#cmd", i), "")
+			}
+
+			memos, err := c.ListAll(context.Background(), "")
+			if err != nil {
+				t.Fatalf("ListAll: %v", err)
+			}
+			if len(memos) != tt.memos {
+				t.Errorf("got %d memos, want %d", len(memos), tt.memos)
+			}
+			seen := make(map[string]bool)
+			for _, m := range memos {
+				if seen[m.Name] {
+					t.Errorf("memo %s returned twice", m.Name)
+				}
+				seen[m.Name] = true
+			}
+			if got := countRequests(srv, "GET", "/api/v1/memos"); got != tt.wantPages {
+				t.Errorf("made %d list requests, want %d", got, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestListUpToStopsEarly(t *testing.T) {
+	srv, c := newTestClient(t)
+	for i := 0; i < 25; i++ {
+		srv.AddMemo(fmt.Sprintf("memo %d", i), "")
+	}
+
+	tests := []struct {
+		limit     int
+		want      int
+		wantPages int
+	}{
+		{0, 25, 5},
+		{3, 3, 1},
+		{5, 5, 1},
+		{6, 6, 2},
+		{100, 25, 5},
+	}
+	for _, tt := range tests {
+		before := countRequests(srv, "GET", "/api/v1/memos")
+		memos, err := c.ListUpTo(context.Background(), client.ListMemosRequest{PageSize: 5}, tt.limit)
+		if err != nil {
+			t.Fatalf("ListUpTo(%d): %v", tt.limit, err)
+		}
+		if len(memos) != tt.want {
+			t.Errorf("ListUpTo(%d) returned %d memos, want %d", tt.limit, len(memos), tt.want)
+		}
+		if got := countRequests(srv, "GET", "/api/v1/memos") - before; got != tt.wantPages {
+			t.Errorf("ListUpTo(%d) made %d requests, want %d", tt.limit, got, tt.wantPages)
+		}
+	}
+}
+
+func TestListEncodesQuery(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Write([]byte(`{"memos": []}`))
+		return true
+	}
+	_, err := c.List(context.Background(), client.ListMemosRequest{
+		Filter:    "tag_search == ['a b','c&d']",
+		PageSize:  20,
+		PageToken: "x=y",
+		OrderBy:   client.OrderByUpdateTime,
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	want := "GET /api/v1/memos?filter=tag_search+%3D%3D+%5B%27a+b%27%2C%27c%26d%27%5D&orderBy=update_time+desc&pageSize=20&pageToken=x%3Dy"
+	if got := srv.Requests(); len(got) != 1 || got[0] != want {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestListTagFilter(t *testing.T) {
+	srv, c := newTestClient(t)
+	srv.AddMemo("```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd #k8s", "")
+	srv.AddMemo("```shell\ngit status\n```\n\n**Tags:**\n#cmd #git", "")
+	srv.AddMemo("just a note #k8s", "")
+
+	tests := []struct {
+		filter string
+		want   int
+	}{
+		{"", 3},
+		{"tag_search == ['cmd']", 2},
+		{"tag_search == ['cmd','k8s']", 1},
+		{"tag_search == ['github']", 0},
+	}
+	for _, tt := range tests {
+		memos, err := c.ListAll(context.Background(), tt.filter)
+		if err != nil {
+			t.Fatalf("ListAll(%q): %v", tt.filter, err)
+		}
+		if len(memos) != tt.want {
+			t.Errorf("ListAll(%q) returned %d memos, want %d", tt.filter, len(memos), tt.want)
+		}
+	}
+}
+
+func TestListPageSize(t *testing.T) {
+	srv, c := newTestClient(t)
+	for i := 0; i < 5; i++ {
+		srv.AddMemo(fmt.Sprintf("memo %d", i), "")
+	}
+
+	page, err := c.List(context.Background(), client.ListMemosRequest{PageSize: 2})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Memos) != 2 || page.NextPageToken == "" {
+		t.Fatalf("got %d memos and token %q, want 2 memos and a next page", len(page.Memos), page.NextPageToken)
+	}
+
+	page, err = c.List(context.Background(), client.ListMemosRequest{PageSize: 2, PageToken: "4"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Memos) != 1 || page.NextPageToken != "" {
+		t.Errorf("last page: got %d memos and token %q, want 1 memo and no token", len(page.Memos), page.NextPageToken)
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	srv := memotest.NewServer(token)
+	defer srv.Close()
+	c := client.New(srv.URL, "wrong-token")
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"list":   func() error { _, err := c.ListAll(ctx, ""); return err },
+		"get":    func() error { _, err := c.Get(ctx, "memos/1"); return err },
+		"create": func() error { _, err := c.Create(ctx, client.CreateMemoRequest{Content: "x"}); return err },
+		"delete": func() error { return c.Delete(ctx, "memos/1") },
+		"tags":   func() error { _, err := c.ListTags(ctx, ""); return err },
+	}
+	for name, call := range calls {
+		err := call()
+		if !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("%s: got %v, want ErrUnauthorized", name, err)
+		}
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.Message != "failed to authenticate user" {
+			t.Errorf("%s: gateway message not decoded: %v", name, err)
+		}
+	}
+}
+
+func TestMalformedResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantDecode bool
+		wantMsg    string
+	}{
+		{"truncated list", http.StatusOK, `{"memos": [{"name": "memos/1"`, true, ""},
+		{"wrong type", http.StatusOK, `{"memos": "nope"}`, true, ""},
+		{"html error page", http.StatusBadGateway, "<html>bad gateway</html>", false, "<html>bad gateway</html>"},
+		{"empty error body", http.StatusInternalServerError, "", false, "Internal Server Error"},
+		{"gateway error", http.StatusBadRequest, `{"code":3,"message":"invalid filter","details":[]}`, false, "invalid filter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, c := newTestClient(t)
+			srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+				return true
+			}
+
+			_, err := c.ListAll(context.Background(), "")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var decodeErr *client.DecodeError
+			if got := errors.As(err, &decodeErr); got != tt.wantDecode {
+				t.Errorf("DecodeError = %v, want %v (err: %v)", got, tt.wantDecode, err)
+			}
+			if tt.wantMsg != "" {
+				var apiErr *client.APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("got %v, want *APIError", err)
+				}
+				if apiErr.Message != tt.wantMsg || apiErr.StatusCode != tt.status {
+					t.Errorf("got %q (HTTP %d), want %q (HTTP %d)", apiErr.Message, apiErr.StatusCode, tt.wantMsg, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	srv, c := newTestClient(t)
+	status := http.StatusOK
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if status == http.StatusOK {
+			return false
+		}
+		w.WriteHeader(status)
+		return true
+	}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusNotFound, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+	} {
+		status = tt.status
+		_, err := c.ListAll(ctx, "")
+		if got := client.IsUnavailable(err); got != tt.want {
+			t.Errorf("status %d: IsUnavailable(%v) = %v, want %v", tt.status, err, got, tt.want)
+		}
+	}
+
+	// A certificate the client does not trust fails the same way every time
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsSrv.Close()
+	if _, err := client.New(tlsSrv.URL, token).ListAll(ctx, ""); err == nil || client.IsUnavailable(err) {
+		t.Errorf("untrusted certificate: IsUnavailable(%v) = true, want false", err)
+	}
+
+	slow := client.New(srv.URL, token)
+	slow.HTTPClient.Timeout = 10 * time.Millisecond
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		time.Sleep(100 * time.Millisecond)
+		return false
+	}
+	if _, err := slow.ListAll(ctx, ""); !client.IsUnavailable(err) {
+		t.Errorf("timeout: IsUnavailable(%v) = false, want true", err)
+	}
+
+	srv.Close()
+	if _, err := c.ListAll(ctx, ""); !client.IsUnavailable(err) {
+		t.Errorf("closed server: IsUnavailable(%v) = false, want true", err)
+	}
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	srv, c := newTestClient(t)
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, client.CreateMemoRequest{
+		Content:    "```shell\nls\n```\n\n**Tags:**\n#cmd #ls",
+		Visibility: client.VisibilityProtected,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.Name == "" || created.UID == "" || created.CreateTime.IsZero() {
+		t.Errorf("created memo missing name, uid or createTime: %+v", created)
+	}
+	if got := c.MemoURL(created); got != srv.URL+"/m/"+created.UID {
+		t.Errorf("MemoURL = %q", got)
+	}
+
+	created.Visibility = client.VisibilityPublic
+	created.Content = "changed"
+	updated, err := c.Update(ctx, created, "visibility")
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Visibility != client.VisibilityPublic || updated.Content == "changed" {
+		t.Errorf("Update applied fields outside the mask: %+v", updated)
+	}
+
+	tagged, err := c.SetTags(ctx, created.Name, []string{"cmd", "ls", "files"})
+	if err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	if want := []string{"cmd", "ls", "files"}; !slices.Equal(tagged.ServerTags(), want) {
+		t.Errorf("tags after SetTags = %v, want %v", tagged.ServerTags(), want)
+	}
+
+	amounts, err := c.ListTags(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if amounts["files"] != 1 {
+		t.Errorf("ListTags = %v, want files counted once", amounts)
+	}
+
+	if err := c.Delete(ctx, created.Name); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Get(ctx, created.Name); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateIfUnchanged(t *testing.T) {
+	srv, c := newTestClient(t)
+	ctx := context.Background()
+	added := srv.AddMemo("```shell\nls\n```", client.VisibilityPrivate)
+
+	mine, err := c.Get(ctx, added.Name)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	since := mine.UpdateTime
+
+	// Someone else edits the memo in the meantime
+	theirs := *mine
+	theirs.Content = "```shell\nls -la\n```"
+	if _, err := c.Update(ctx, &theirs, "content"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	mine.Content = "```shell\nls -l\n```"
+	_, err = c.UpdateIfUnchanged(ctx, mine, since, "content")
+	var conflict *client.ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("UpdateIfUnchanged after a concurrent edit: got %v, want ConflictError", err)
+	}
+	if conflict.Current.Content != theirs.Content {
+		t.Errorf("conflict reports content %q, want %q", conflict.Current.Content, theirs.Content)
+	}
+
+	updated, err := c.UpdateIfUnchanged(ctx, mine, conflict.Current.UpdateTime, "content")
+	if err != nil {
+		t.Fatalf("UpdateIfUnchanged with the current updateTime: %v", err)
+	}
+	if updated.Content != mine.Content {
+		t.Errorf("content = %q, want %q", updated.Content, mine.Content)
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"", " "}, ""},
+		{[]string{"cmd"}, "tag_search == ['cmd']"},
+		{[]string{"#cmd", " k8s "}, "tag_search == ['cmd','k8s']"},
+		{[]string{`it's`, `a\b`}, `tag_search == ['it\'s','a\\b']`},
+	}
+	for _, tt := range tests {
+		if got := client.TagFilter(tt.tags); got != tt.want {
+			t.Errorf("TagFilter(%q) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	srv, c := newTestClient(t)
+	ctx := context.Background()
+	stale := srv.AddMemo("```shell\nold\n```\n\n**Tags:**\n#cmd", "")
+	srv.AddMemo("```shell\nnew\n```\n\n**Tags:**\n#cmd", "")
+
+	archived, err := c.Archive(ctx, stale.Name)
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if archived.RowStatus != client.RowStatusArchived || archived.Content != stale.Content {
+		t.Errorf("archived memo = %+v", archived)
+	}
+	memos, err := c.ListAll(ctx, "")
+	if err != nil {
+		t.Fatalf("ListAll: %v", err)
+	}
+	if len(memos) != 1 || memos[0].Name == stale.Name {
+		t.Errorf("ListAll after Archive = %v, want only the active memo", memos)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	srv := memotest.NewServer("secret")
+	t.Cleanup(srv.Close)
+	srv.AddMemo("```shell\nls\n```", "")
+
+	calls := 0
+	c := client.New(srv.URL, "")
+	c.TokenSource = func() (string, error) {
+		calls++
+		return "secret", nil
+	}
+	if calls != 0 {
+		t.Fatal("TokenSource called before the first request")
+	}
+	for range 2 {
+		if _, err := c.ListAll(context.Background(), ""); err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("TokenSource called %d times, want once", calls)
+	}
+
+	failing := client.New(srv.URL, "")
+	failing.TokenSource = func() (string, error) {
+		calls++
+		return "", errors.New("keyring locked")
+	}
+	calls = 0
+	for range 2 {
+		_, err := failing.ListAll(context.Background(), "")
+		if err == nil || !strings.Contains(err.Error(), "keyring locked") {
+			t.Errorf("ListAll with a failing TokenSource: got %v", err)
+		}
+	}
+	if calls != 1 || len(srv.Requests()) != 2 {
+		t.Errorf("failing TokenSource called %d times with %d requests sent, want 1 call and no new requests", calls, len(srv.Requests()))
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is matched by API errors for a missing or invalid token.
+	ErrUnauthorized = errors.New("memos: unauthorized")
+	// ErrNotFound is matched by API errors for a memo that does not exist.
+	ErrNotFound = errors.New("memos: not found")
+	// ErrConflict is matched by a ConflictError.
+	ErrConflict = errors.New("memos: memo changed on the server")
+)
+
+// ConflictError reports a memo modified on the server since it was read.
+type ConflictError struct {
+	Current *Memo
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("memos: %s changed on the server at %s", e.Current.Name, e.Current.UpdateTime.Local().Format(time.DateTime))
+}
+
+// Is lets errors.Is match a ConflictError against ErrConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// APIError is an error response from the Memos gRPC gateway.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+}
+
+// newAPIError decodes an error body, falling back to the raw body when it is
+// not a gateway error.
+func newAPIError(status int, body []byte) *APIError {
+	e := &APIError{StatusCode: status}
+	if err := json.Unmarshal(body, e); err != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+		if e.Message == "" {
+			e.Message = http.StatusText(status)
+		}
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("memos: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// Is lets errors.Is match an APIError against ErrUnauthorized and ErrNotFound.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// DecodeError reports a successful response whose body could not be decoded.
+type DecodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("memos: decoding %s response: %v", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnavailable reports whether err means the server could not be reached,
+// did not answer in time or is down behind a proxy, so the same request may
+// succeed later. Other failures, such as TLS errors or a server error caused
+// by the request, repeat on every try.
+func IsUnavailable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"memo/snippet"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Visibility controls who can see a memo.
+type Visibility string
+
+const (
+	VisibilityPrivate   Visibility = "PRIVATE"
+	VisibilityProtected Visibility = "PROTECTED"
+	VisibilityPublic    Visibility = "PUBLIC"
+)
+
+// Row statuses of a memo; archived memos are hidden from listings.
+const (
+	RowStatusActive   = "ACTIVE"
+	RowStatusArchived = "ARCHIVED"
+)
+
+// MemoProperty holds the properties the server derives from the content.
+type MemoProperty struct {
+	Tags        []string `json:"tags,omitempty"`
+	HasLink     bool     `json:"hasLink,omitempty"`
+	HasTaskList bool     `json:"hasTaskList,omitempty"`
+	HasCode     bool     `json:"hasCode,omitempty"`
+}
+
+// Memo is a memo resource.
+type Memo struct {
+	// Name is the resource name, memos/{id}.
+	Name        string        `json:"name,omitempty"`
+	UID         string        `json:"uid,omitempty"`
+	Creator     string        `json:"creator,omitempty"`
+	CreateTime  time.Time     `json:"createTime"`
+	UpdateTime  time.Time     `json:"updateTime"`
+	DisplayTime time.Time     `json:"displayTime"`
+	Content     string        `json:"content"`
+	Visibility  Visibility    `json:"visibility,omitempty"`
+	Pinned      bool          `json:"pinned"`
+	RowStatus   string        `json:"rowStatus,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Property    *MemoProperty `json:"property,omitempty"`
+}
+
+// ServerTags returns the tags the server extracted from the memo content.
+func (m *Memo) ServerTags() []string {
+	if len(m.Tags) > 0 {
+		return m.Tags
+	}
+	if m.Property != nil {
+		return m.Property.Tags
+	}
+	return nil
+}
+
+// TagList returns the memo's tags: the server's list when it has one,
+// otherwise the tags of the **Tags:** section of the content.
+func (m *Memo) TagList() []string {
+	if tags := m.ServerTags(); len(tags) > 0 {
+		return tags
+	}
+	return snippet.ExtractTags(m.Content)
+}
+
+// TagFilter builds a tag_search filter expression matching memos carrying
+// all of the tags. Empty tags are ignored; no tags means no filter.
+func TagFilter(tags []string) string {
+	var quoted []string
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" {
+			continue
+		}
+		tag = strings.ReplaceAll(tag, `\`, `\\`)
+		tag = strings.ReplaceAll(tag, `'`, `\'`)
+		quoted = append(quoted, "'"+tag+"'")
+	}
+	if len(quoted) == 0 {
+		return ""
+	}
+	return "tag_search == [" + strings.Join(quoted, ",") + "]"
+}
+
+// Update time orders: OrderByUpdateTime lists the most recently updated
+// memos first, OrderByUpdateTimeAsc the least recently updated. Servers
+// without order_by support ignore them and use their default order: pinned
+// memos first, then by display time.
+const (
+	OrderByUpdateTime    = "update_time desc"
+	OrderByUpdateTimeAsc = "update_time asc"
+)
+
+// ListMemosRequest selects one page of memos.
+type ListMemosRequest struct {
+	// Filter is a CEL expression, e.g. tag_search == ['cmd'].
+	Filter    string
+	PageSize  int
+	PageToken string
+	// OrderBy is the sort order, e.g. OrderByUpdateTime.
+	OrderBy string
+}
+
+// ListMemosResponse is one page of memos.
+type ListMemosResponse struct {
+	Memos         []Memo `json:"memos"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// CreateMemoRequest is the body of a memo creation.
+type CreateMemoRequest struct {
+	Content    string     `json:"content"`
+	Visibility Visibility `json:"visibility,omitempty"`
+}
+
+type listTagsResponse struct {
+	TagAmounts map[string]int `json:"tagAmounts"`
+}
+
+// List returns one page of memos.
+func (c *Client) List(ctx context.Context, req ListMemosRequest) (*ListMemosResponse, error) {
+	query := url.Values{}
+	if req.Filter != "" {
+		query.Set("filter", req.Filter)
+	}
+	if req.PageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(req.PageSize))
+	}
+	if req.PageToken != "" {
+		query.Set("pageToken", req.PageToken)
+	}
+	if req.OrderBy != "" {
+		query.Set("orderBy", req.OrderBy)
+	}
+
+	var resp ListMemosResponse
+	if err := c.do(ctx, http.MethodGet, "memos", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListAll follows pagination and returns every memo matching filter.
+func (c *Client) ListAll(ctx context.Context, filter string) ([]Memo, error) {
+	return c.ListUpTo(ctx, ListMemosRequest{Filter: filter}, 0)
+}
+
+// ListUpTo follows pagination from req and returns at most limit memos, or
+// every memo when limit is zero. It stops requesting pages once limit is hit.
+func (c *Client) ListUpTo(ctx context.Context, req ListMemosRequest, limit int) ([]Memo, error) {
+	var memos []Memo
+	for {
+		page, err := c.List(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		memos = append(memos, page.Memos...)
+		if limit > 0 && len(memos) >= limit {
+			return memos[:limit], nil
+		}
+		if page.NextPageToken == "" {
+			return memos, nil
+		}
+		req.PageToken = page.NextPageToken
+	}
+}
+
+// Get returns the memo with the given resource name.
+func (c *Client) Get(ctx context.Context, name string) (*Memo, error) {
+	var memo Memo
+	if err := c.do(ctx, http.MethodGet, name, nil, nil, &memo); err != nil {
+		return nil, err
+	}
+	return &memo, nil
+}
+
+// Create creates a memo and returns it as stored by the server.
+func (c *Client) Create(ctx context.Context, req CreateMemoRequest) (*Memo, error) {
+	var memo Memo
+	if err := c.do(ctx, http.MethodPost, "memos", nil, req, &memo); err != nil {
+		return nil, err
+	}
+	return &memo, nil
+}
+
+// Update writes the fields of memo named in updateMask, e.g. "content" or
+// "visibility", and returns the updated memo.
+func (c *Client) Update(ctx context.Context, memo *Memo, updateMask ...string) (*Memo, error) {
+	query := url.Values{}
+	query.Set("updateMask", strings.Join(updateMask, ","))
+
+	var updated Memo
+	if err := c.do(ctx, http.MethodPatch, memo.Name, query, memo, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// UpdateIfUnchanged is Update guarded against lost updates: it fails with a
+// *ConflictError when the memo was modified on the server after since, the
+// updateTime of the copy the changes were made to. The API has no
+// conditional update, so a write landing between the check and the update
+// is still overwritten.
+func (c *Client) UpdateIfUnchanged(ctx context.Context, memo *Memo, since time.Time, updateMask ...string) (*Memo, error) {
+	current, err := c.Get(ctx, memo.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !current.UpdateTime.Equal(since) {
+		return nil, &ConflictError{Current: current}
+	}
+	return c.Update(ctx, memo, updateMask...)
+}
+
+// Archive archives the memo with the given resource name.
+func (c *Client) Archive(ctx context.Context, name string) (*Memo, error) {
+	return c.Update(ctx, &Memo{Name: name, RowStatus: RowStatusArchived}, "row_status")
+}
+
+// Delete deletes the memo with the given resource name.
+func (c *Client) Delete(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, name, nil, nil, nil)
+}
+
+// SetTags replaces the **Tags:** section of a memo's content with tags.
+func (c *Client) SetTags(ctx context.Context, name string, tags []string) (*Memo, error) {
+	memo, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	memo.Content = snippet.ReplaceTags(memo.Content, tags)
+	return c.Update(ctx, memo, "content")
+}
+
+// ListTags returns every tag in use with the number of memos carrying it.
+func (c *Client) ListTags(ctx context.Context, filter string) (map[string]int, error) {
+	query := url.Values{}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+
+	var resp listTagsResponse
+	if err := c.do(ctx, http.MethodGet, "memos/-/tags", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.TagAmounts, nil
+}
+
+// MemoURL returns the web URL of a memo.
+func (c *Client) MemoURL(memo *Memo) string {
+	if memo.UID != "" {
+		return c.BaseURL + "/m/" + memo.UID
+	}
+	return c.BaseURL + "/" + memo.Name
+}
@@ -1,203 +1,753 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"memo/cache"
+	"memo/client"
+	"memo/query"
+	"memo/snippet"
 	"memo/sunbeam"
-	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-type MemoContent struct {
-	Content string `json:"content"`
+// shellQuote quotes a word for use in a shell command line.
+func shellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
 }
 
-type MemoResponse struct {
-	NextPageToken string `json:"nextPageToken"`
-	Memos         []Memo `json:"memos"`
+// deleteCommand returns the shell command deleting the memo name with the
+// binary self. The profile is passed on so the memo is deleted on the
+// server it was listed from.
+func deleteCommand(self, profile, name string) string {
+	command := shellQuote(self)
+	if profile != "" {
+		command += " --profile " + shellQuote(profile)
+	}
+	// Linked as get-memos there is no list subcommand
+	if filepath.Base(self) != "get-memos" {
+		command += " list"
+	}
+	return command + " --delete " + shellQuote(name)
 }
 
-// extractCommand parses the command from the shell code block
-func extractCodeBlock(content string) string {
-	// Match the content inside the shell code block
-	re := regexp.MustCompile("(?s)```\\w*\\n(.*?)\\n```")
-	matches := re.FindStringSubmatch(content)
-	if len(matches) > 1 {
-		return matches[1]
+// sunbeamList builds a Sunbeam list page with one item per command. profile
+// is the memos profile the entries were listed from.
+func sunbeamList(entries []commandEntry, profile string) sunbeam.List {
+	// The delete action calls back into this binary, through the delete
+	// extension command when Sunbeam runs it as an extension
+	deleteAction := func(name string) sunbeam.Action {
+		if extensionPreferences != nil {
+			return sunbeam.Action{Title: "Delete Memo", Type: sunbeam.ActionRun, Command: "delete", Params: map[string]any{"name": name}, Reload: true}
+		}
+		self, err := os.Executable()
+		if err != nil {
+			self = os.Args[0]
+		}
+		return sunbeam.Action{Title: "Delete Memo", Type: sunbeam.ActionExec, Command: deleteCommand(self, profile, name), Reload: true}
+	}
+
+	var items []sunbeam.ListItem
+	for _, e := range entries {
+		// Titles are single line, multi-line commands show their first line
+		title, _, multiline := strings.Cut(e.Cmd, "\n")
+		if multiline {
+			title += " …"
+		}
+		subtitle := ""
+		if e.Tags != "" {
+			subtitle = "#" + strings.ReplaceAll(e.Tags, " ", " #")
+		}
+		accessories := []string{e.Visibility, e.UpdateTime.Local().Format("2006-01-02")}
+		if e.Lang != "" {
+			accessories = append([]string{e.Lang}, accessories...)
+		}
+		if e.Pinned {
+			accessories = append([]string{"pinned"}, accessories...)
+		}
+
+		items = append(items, sunbeam.ListItem{
+			Title:       title,
+			Subtitle:    subtitle,
+			Accessories: accessories,
+			Actions: []sunbeam.Action{
+				{Title: "Copy Command", Type: sunbeam.ActionCopy, Text: e.Cmd, Exit: true},
+				{Title: "Run in Terminal", Type: sunbeam.ActionExec, Command: e.Cmd},
+				{Title: "Open Memo", Type: sunbeam.ActionOpen, URL: e.URL},
+				{Title: "Copy Memo Link", Type: sunbeam.ActionCopy, Text: e.URL, Exit: true},
+				deleteAction(e.Name),
+			},
+		})
 	}
-	return ""
+
+	list := sunbeam.NewList(items...)
+	list.EmptyText = "No command memos found"
+	return list
 }
 
-// extractTags parses the tags from the Tags section
-func extractTags(content string) []string {
-	// Match the line starting with **Tags:** and extract hashtags
-	re := regexp.MustCompile(`(?i)(#[a-zA-Z0-9-_]+(?:\s*#[a-zA-Z0-9-_]+)*)`)
+// commandEntry is one code block of a memo as printed by get-memos, along
+// with the metadata of the memo it came from.
+type commandEntry struct {
+	Cmd  string `json:"cmd"`
+	Lang string `json:"lang,omitempty"`
+	Tags string `json:"tags"`
+
+	Name       string    `json:"name"`
+	UID        string    `json:"uid,omitempty"`
+	Creator    string    `json:"creator,omitempty"`
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+	Visibility string    `json:"visibility"`
+	Pinned     bool      `json:"pinned"`
+	URL        string    `json:"url"`
+
+	score int
+}
 
-	matches := re.FindStringSubmatch(content)
-	fmt.Println(matches)
-	if len(matches) > 1 {
-		tagsLine := matches[1]
-		tags := strings.Fields(tagsLine) // Split by spaces
-		for i, tag := range tags {
-			tags[i] = strings.TrimPrefix(tag, "#") // Remove leading '#'
+// newCommandEntry returns the entry for one code block of memo.
+func newCommandEntry(c *client.Client, memo *client.Memo, block snippet.CodeBlock, score int) commandEntry {
+	return commandEntry{
+		Cmd:        block.Code,
+		Lang:       block.Lang,
+		Tags:       strings.Join(memo.TagList(), " "),
+		Name:       memo.Name,
+		UID:        memo.UID,
+		Creator:    memo.Creator,
+		CreateTime: memo.CreateTime,
+		UpdateTime: memo.UpdateTime,
+		Visibility: strings.ToLower(string(memo.Visibility)),
+		Pinned:     memo.Pinned,
+		URL:        c.MemoURL(memo),
+		score:      score,
+	}
+}
+
+// memoID returns the short id of the memo, "12" for "memos/12".
+func (e commandEntry) memoID() string {
+	return strings.TrimPrefix(e.Name, "memos/")
+}
+
+// sortEntries orders entries by the given key: "relevance" keeps the best
+// query matches first, "recent" the most recently updated memos, "created"
+// the newest memos and "pinned" puts pinned memos first, then by recency.
+// Ties keep the server order.
+func sortEntries(entries []commandEntry, by string) error {
+	var less func(a, b commandEntry) bool
+	switch by {
+	case "relevance":
+		less = func(a, b commandEntry) bool { return a.score > b.score }
+	case "recent":
+		less = func(a, b commandEntry) bool { return a.UpdateTime.After(b.UpdateTime) }
+	case "created":
+		less = func(a, b commandEntry) bool { return a.CreateTime.After(b.CreateTime) }
+	case "pinned":
+		less = func(a, b commandEntry) bool {
+			if a.Pinned != b.Pinned {
+				return a.Pinned
+			}
+			return a.UpdateTime.After(b.UpdateTime)
 		}
-		return tags
+	default:
+		return fmt.Errorf("unknown sort order %q", by)
 	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
 	return nil
 }
 
-// Function to filter commands based on a tag
-func filterCommandsByTag(resultSlice []map[string]string, tag string) map[string]string {
-	// Create a map to hold the filtered results
-	filteredResults := make(map[string]string)
+// terminalWidth returns the width of the terminal, 80 when it is unknown.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		fields := strings.Fields(string(out))
+		if len(fields) == 2 {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return 80
+}
 
-	// Iterate through the slice and check if the "tags" contain the specified tag
-	for _, result := range resultSlice {
-		// Check if the "tags" contain the provided tag (case-sensitive)
-		if strings.Contains(result["tags"], tag) {
-			// Add the command to the filtered map
-			filteredResults[result["name"]] = result["tags"]
+// singleLine joins a multi-line command into one line, dropping indentation,
+// merging backslash continuations and separating other lines with ; unless
+// they already end in a separator or a keyword that continues the command.
+func singleLine(command string) string {
+	var b strings.Builder
+	lines := strings.Split(command, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			line = strings.TrimLeft(line, " \t")
 		}
+		if i < len(lines)-1 {
+			if cont, ok := strings.CutSuffix(line, "\\"); ok {
+				b.WriteString(strings.TrimRight(cont, " ") + " ")
+				continue
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if continuesLine(line) {
+				b.WriteString(strings.TrimRight(line, " ") + " ")
+				continue
+			}
+			b.WriteString(line + "; ")
+			continue
+		}
+		b.WriteString(line)
 	}
+	return b.String()
+}
 
-	return filteredResults
+// continuesLine reports whether a non-blank shell line needs no ; before the
+// next one, e.g. "cmd;", "cmd |" or "for f in *; do".
+func continuesLine(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	for _, end := range []string{";", "&", "|", "{", "("} {
+		if strings.HasSuffix(line, end) {
+			return true
+		}
+	}
+	fields := strings.Fields(line)
+	switch fields[len(fields)-1] {
+	case "do", "then", "else", "in":
+		return true
+	}
+	return false
 }
 
-type Memo struct {
-	Content string `json:"content"`
+// truncate shortens s to at most width runes, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "…"
 }
 
-type Response struct {
-	NextPageToken string `json:"nextPageToken"`
-	Memos         []Memo `json:"memos"`
+// writeTable prints the memo id, update date, command and tags in columns
+// fitted to width. Pinned memos are marked with a star after their id.
+func writeTable(w io.Writer, entries []commandEntry, width int) {
+	idWidth, tagsWidth := len("ID"), len("TAGS")
+	for _, e := range entries {
+		idWidth = max(idWidth, len(e.memoID())+1)
+		tagsWidth = max(tagsWidth, utf8.RuneCountInString(e.Tags))
+	}
+	const dateWidth = len("2006-01-02")
+	tagsWidth = min(tagsWidth, width/3)
+	cmdWidth := max(width-idWidth-dateWidth-tagsWidth-6, 10)
+
+	fmt.Fprintf(w, "%-*s  %-*s  %-*s  %s\n", idWidth, "ID", dateWidth, "UPDATED", cmdWidth, "COMMAND", "TAGS")
+	for _, e := range entries {
+		id := e.memoID()
+		if e.Pinned {
+			id += "*"
+		}
+		cmd := truncate(singleLine(e.Cmd), cmdWidth)
+		pad := cmdWidth - utf8.RuneCountInString(cmd)
+		fmt.Fprintf(w, "%-*s  %s  %s%s  %s\n", idWidth, id, e.UpdateTime.Local().Format("2006-01-02"),
+			cmd, strings.Repeat(" ", pad), truncate(e.Tags, tagsWidth))
+	}
 }
 
-func getMemos(token string, apiURL string) ([]Memo, error) {
-	var allMemos []Memo
-	url := apiURL
+// writeScript prints a shell script listing every command, each preceded by
+// its memo and tags as comments. Non-shell snippets are commented out.
+func writeScript(w io.Writer, entries []commandEntry) {
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintf(w, "# Command memos exported by get-memos on %s\n", time.Now().Format("2006-01-02"))
+	for _, e := range entries {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "# %s (%s, updated %s) %s\n", e.Name, e.Visibility, e.UpdateTime.Local().Format("2006-01-02"), e.URL)
+		if e.Tags != "" {
+			fmt.Fprintf(w, "# #%s\n", strings.ReplaceAll(e.Tags, " ", " #"))
+		}
+		// Snippets in other languages are kept for reference but not run
+		if !(snippet.CodeBlock{Lang: e.Lang}).MatchesLang("shell") {
+			fmt.Fprintf(w, "# [%s]\n# %s\n", e.Lang, strings.ReplaceAll(e.Cmd, "\n", "\n# "))
+			continue
+		}
+		fmt.Fprintln(w, e.Cmd)
+	}
+}
 
-	// Loop to handle pagination
-	for {
-		// Create the request
-		req, err := http.NewRequest("GET", url, nil)
+// writeEntries prints the commands in one of the text formats. width is the
+// line width the table format is fitted to.
+func writeEntries(w io.Writer, format string, entries []commandEntry, width int) error {
+	switch format {
+	case "json":
+		jsonData, err := json.MarshalIndent(entries, "", "  ")
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %v", err)
+			return fmt.Errorf("converting to JSON: %w", err)
+		}
+		fmt.Fprintln(w, string(jsonData))
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return fmt.Errorf("converting to JSON: %w", err)
+			}
+		}
+	case "plain":
+		for _, e := range entries {
+			fmt.Fprintln(w, e.Cmd)
 		}
+	case "fzf":
+		// One line per command: command<TAB>tags<TAB>name<TAB>url
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", singleLine(e.Cmd), e.Tags, e.Name, e.URL)
+		}
+	case "script":
+		writeScript(w, entries)
+	case "table":
+		writeTable(w, entries, width)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	return nil
+}
 
-		// Add Authorization header
-		req.Header.Add("Authorization", "Bearer "+token)
+// fetchMemos returns the memos matching filter, from the server directly
+// with noCache, otherwise through the local cache after syncing it. When the
+// server is unreachable the cached memos are used.
+//
+// enough, when not nil, is called with each page read from the server and
+// stops paging once it returns true. Syncing the cache reads every page, as
+// a partial sync would leave the cache incomplete, so when a full sync is
+// due the server is read directly instead, like noCache. An incremental
+// sync already stops at the memos it has.
+func fetchMemos(c *client.Client, filter string, pageSize int, enough func(page []client.Memo) bool, offline, refresh, noCache bool) ([]client.Memo, error) {
+	ctx := context.Background()
+	req := client.ListMemosRequest{Filter: filter, PageSize: pageSize}
+	if noCache {
+		return listUntil(ctx, c, req, enough)
+	}
 
-		// Send the request
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	memoCache, err := cache.Load(c.BaseURL, filter)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case offline:
+		if memoCache.SyncedAt.IsZero() {
+			return nil, fmt.Errorf("no cached memos yet, run once without --offline")
+		}
+	case enough != nil && !refresh && !memoCache.Incremental():
+		memos, err := listUntil(ctx, c, req, enough)
+		if err == nil || !client.IsUnavailable(err) || memoCache.SyncedAt.IsZero() {
+			return memos, err
+		}
+		log.Printf("Warning: %v; using memos cached on %s", err, memoCache.SyncedAt.Local().Format(time.DateTime))
+	default:
+		_, err := memoCache.Sync(ctx, c, refresh)
 		if err != nil {
-			return nil, fmt.Errorf("failed to send request: %v", err)
+			if !client.IsUnavailable(err) || memoCache.SyncedAt.IsZero() {
+				return nil, err
+			}
+			log.Printf("Warning: %v; using memos cached on %s", err, memoCache.SyncedAt.Local().Format(time.DateTime))
+			break
+		}
+		if err := memoCache.Save(); err != nil {
+			log.Printf("Warning: could not save cache: %v", err)
 		}
-		defer resp.Body.Close()
+	}
+	return memoCache.Memos, nil
+}
 
-		// Read the response body
-		body, err := io.ReadAll(resp.Body)
+// listUntil follows pagination from req until enough returns true for a
+// page, or through every page when enough is nil.
+func listUntil(ctx context.Context, c *client.Client, req client.ListMemosRequest, enough func(page []client.Memo) bool) ([]client.Memo, error) {
+	var memos []client.Memo
+	for {
+		page, err := c.List(ctx, req)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response body: %v", err)
+			return nil, err
+		}
+		memos = append(memos, page.Memos...)
+		if page.NextPageToken == "" || enough != nil && enough(page.Memos) {
+			return memos, nil
 		}
+		req.PageToken = page.NextPageToken
+	}
+}
 
-		// Check if the request was successful
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// matchEntries returns an entry for every code block of memos in the
+// language lang, or any language when it is empty, that matches q.
+func matchEntries(c *client.Client, memos []client.Memo, q *query.Query, lang string) []commandEntry {
+	entries := []commandEntry{}
+	for i := range memos {
+		memo := &memos[i]
+		tagList := memo.TagList()
+		for _, block := range snippet.CommandBlocks(memo.Content, lang) {
+			score, ok := q.Match(query.Doc{Command: block.Code, Tags: tagList, Lang: block.Lang})
+			if !ok {
+				continue
+			}
+			entries = append(entries, newCommandEntry(c, memo, block, score))
 		}
+	}
+	return entries
+}
 
-		// Parse the JSON response
-		var response Response
-		err = json.Unmarshal(body, &response)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse response JSON: %v", err)
+// paramsPath returns the file remembering the last value of each command
+// parameter.
+func paramsPath() string {
+	return filepath.Join(stateDir(), "params.json")
+}
+
+// loadParamDefaults returns the parameter values used last time, keyed by
+// parameter name. A missing or unreadable file gives no defaults.
+func loadParamDefaults() map[string]string {
+	defaults := make(map[string]string)
+	data, err := os.ReadFile(paramsPath())
+	if err == nil {
+		if err := json.Unmarshal(data, &defaults); err != nil {
+			log.Printf("Warning: ignoring %s: %v", paramsPath(), err)
 		}
+	}
+	return defaults
+}
+
+// saveParamDefaults remembers the parameter values for next time.
+func saveParamDefaults(defaults map[string]string) error {
+	path := paramsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(defaults, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// pickEntry asks which command to use when the query matched several.
+func pickEntry(reader *bufio.Reader, w io.Writer, entries []commandEntry) (commandEntry, error) {
+	if len(entries) == 0 {
+		return commandEntry{}, errors.New("no command matches the query")
+	}
+	if len(entries) == 1 {
+		return entries[0], nil
+	}
 
-		// Append the memos to the allMemos slice
-		allMemos = append(allMemos, response.Memos...)
+	const maxChoices = 20
+	shown := entries[:min(len(entries), maxChoices)]
+	width := terminalWidth()
+	for i, e := range shown {
+		fmt.Fprintf(w, "%3d) %s\n", i+1, truncate(singleLine(e.Cmd), width-5))
+	}
+	if len(entries) > maxChoices {
+		fmt.Fprintf(w, "     … %d more, refine the query to see them\n", len(entries)-maxChoices)
+	}
+	for {
+		fmt.Fprintf(w, "Pick a command [1]: ")
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" && err == nil {
+			return shown[0], nil
+		}
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(shown) {
+			return shown[n-1], nil
+		}
+		if err != nil {
+			return commandEntry{}, errors.New("no command picked")
+		}
+		fmt.Fprintf(w, "Enter a number between 1 and %d\n", len(shown))
+	}
+}
 
-		// Check if there is a next page, and if so, update the URL
-		if response.NextPageToken == "" {
-			break // No more pages, exit the loop
+// promptParams asks for the value of each parameter, offering the value used
+// last time as the default. Parameters without a default must be given.
+func promptParams(reader *bufio.Reader, w io.Writer, names []string, defaults map[string]string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range names {
+		for {
+			if def, ok := defaults[name]; ok {
+				fmt.Fprintf(w, "%s [%s]: ", name, def)
+			} else {
+				fmt.Fprintf(w, "%s: ", name)
+			}
+			line, err := reader.ReadString('\n')
+			value := strings.TrimRight(line, "\r\n")
+			if value == "" {
+				value = defaults[name]
+			}
+			if value != "" {
+				values[name] = value
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("no value for %s", name)
+			}
 		}
-		url = fmt.Sprintf("%s&pageToken=%s", apiURL, response.NextPageToken)
-		//fmt.Println(url)
 	}
+	return values, nil
+}
 
-	return allMemos, nil
+// confirm asks a yes/no question defaulting to yes.
+func confirm(reader *bufio.Reader, w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [Y/n]: ", question)
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// confirmNo asks a yes/no question defaulting to no, for destructive
+// actions.
+func confirmNo(reader *bufio.Reader, w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+	line, _ := reader.ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// confirmDelete asks on the terminal whether to delete the memo name. The
+// terminal is used even when Sunbeam runs the delete action, falling back
+// to stdin and stderr without one.
+func confirmDelete(name string) bool {
+	var in io.Reader = os.Stdin
+	var w io.Writer = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, w = tty, tty
+	}
+	return confirmNo(bufio.NewReader(in), w, "Delete "+name+" permanently?")
 }
 
-func main() {
-	// Example path to the Sunbeam configuration file
-	configPath := filepath.Join(os.Getenv("HOME"), ".config", "sunbeam", "sunbeam.json")
-	// Retrieve memo preferences
-	preferences, err := sunbeam.ReadSunbeamConfig(configPath)
+// appendHistory adds command to the shell history file so it can be recalled
+// with the arrow keys once the shell reloads its history (history -r in bash,
+// fc -R in zsh).
+func appendHistory(command string) error {
+	historyFile := os.Getenv("HISTFILE")
+	if historyFile == "" {
+		historyFile = os.ExpandEnv("$HOME/.bash_history")
+		if strings.HasSuffix(os.Getenv("SHELL"), "zsh") {
+			historyFile = os.ExpandEnv("$HOME/.zsh_history")
+		}
+	}
+	f, err := os.OpenFile(historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		return fmt.Errorf("failed to open history file: %w", err)
 	}
+	defer f.Close()
 
-	apiKey := ""
-	apiURL := ""
-	if len(preferences.MemoToken) == 0 || len(preferences.MemoURL) == 0 {
-		fmt.Printf("Error: no values found in sunbeam memo extension configuration ... trying environment variables")
+	line := command
+	if strings.Contains(filepath.Base(historyFile), "zsh") {
+		// zsh extended history: ": <start>:<duration>;<command>"
+		line = fmt.Sprintf(": %d:0;%s", time.Now().Unix(), strings.ReplaceAll(command, "\n", "\\\n"))
+	}
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
 
-		apiKey = os.Getenv("USEMEMOS_API_KEY")
-		apiURL = os.Getenv("USEMEMOS_API_URL")
-	} else {
-		apiKey = preferences.MemoToken
-		apiURL = preferences.MemoURL
+// runSaved picks one of the entries, fills in its parameters and runs it
+// after confirmation. With insert the final command is printed on stdout
+// instead, for shell key bindings that put it on the command line:
+//
+//	bash: bind -x '"\C-g": READLINE_LINE=$(memo run --insert); READLINE_POINT=${#READLINE_LINE}'
+//	zsh:  memo-run() { LBUFFER=$(memo run --insert </dev/tty); zle reset-prompt }; zle -N memo-run; bindkey '^g' memo-run
+//
+// With history it is appended to the shell history instead. Prompts are
+// read from the terminal and written to stderr so stdout stays clean.
+func runSaved(entries []commandEntry, insert, history bool) error {
+	in := os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		in = tty
 	}
+	reader := bufio.NewReader(in)
+	w := os.Stderr
 
-	if apiKey == "" || apiURL == "" {
-		fmt.Println("Environment variables USEMEMOS_API_KEY and USEMEMOS_API_URL must be set. ... OR ")
-		fmt.Println("add token and url in sunbeam memos configuration")
-		os.Exit(1)
+	entry, err := pickEntry(reader, w, entries)
+	if err != nil {
+		return err
+	}
+	command := entry.Cmd
+	if names := snippet.Placeholders(command); len(names) > 0 {
+		defaults := loadParamDefaults()
+		values, err := promptParams(reader, w, names, defaults)
+		if err != nil {
+			return err
+		}
+		for name, value := range values {
+			defaults[name] = value
+		}
+		if err := saveParamDefaults(defaults); err != nil {
+			log.Printf("Warning: could not remember parameter values: %v", err)
+		}
+		command = snippet.FillPlaceholders(command, values)
 	}
 
+	switch {
+	case insert:
+		fmt.Println(command)
+		return nil
+	case history:
+		if err := appendHistory(command); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Added to shell history: %s\n", command)
+		return nil
+	}
+
+	fmt.Fprintf(w, "$ %s\n", command)
+	if !confirm(reader, w, "Run this command?") {
+		return errors.New("cancelled")
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+// listMemos implements memo list and memo run, the former get-memos
+// command: it prints the command memos matching a query or, in runMode,
+// picks one matching command, fills in its parameters and runs it.
+func listMemos(args []string, profile string, runMode bool) {
 	// Parse command-line arguments for additional filter tags
-	//tags := flag.String("tags", "cmd,shell,script", "Comma-separated list of tags to filter memos (e.g., 'cmd,shell,script')")
-	tags := flag.String("tags", "", "Comma-separated list of tags to filter memos (e.g., 'cmd,shell,script')")
-	flag.Parse()
+	name := "list"
+	if runMode {
+		name = "run"
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	//tags := fs.String("tags", "cmd,shell,script", "Comma-separated list of tags to filter memos (e.g., 'cmd,shell,script')")
+	tags := fs.String("tags", "", "Comma-separated list of tags to filter memos (e.g., 'cmd,shell,script')")
+	limit := fs.Int("limit", 0, "Maximum number of commands to list (0 for all), applied after the query and sort; without search words and with the relevance sort, paging stops once enough commands match")
+	pageSize := fs.Int("page-size", 50, "Number of memos requested per page when reading the server directly")
+	offline := fs.Bool("offline", false, "Serve memos from the local cache without contacting the server")
+	refresh := fs.Bool("refresh", false, "Force a full resync of the local cache")
+	noCache := fs.Bool("no-cache", false, "Fetch memos directly from the server, bypassing the local cache")
+	format := fs.String("format", "json", "Output format: table, plain, json, ndjson, fzf, script or sunbeam\n"+
+		"fzf prints command<TAB>tags<TAB>name<TAB>url, e.g. memo list --format fzf | fzf --delimiter '\\t' --with-nth 1 --preview 'echo {2}; echo {4}'")
+	queryFlag := fs.String("query", "", `Local search, e.g. 'tag:k8s AND NOT tag:old "rollout restart"'; arguments are appended`)
+	lang := fs.String("lang", "", "Only list code blocks in this language (e.g. shell, sql, yaml)")
+	sortBy := fs.String("sort", "relevance", "Sort order: relevance, recent, created or pinned; relevance keeps the server order without a query")
+	fs.StringVar(&profile, "profile", profile, profileUsage)
+	deleteName := fs.String("delete", "", "Delete the memo with this name (e.g. memos/12) after confirmation and exit")
+	yes := fs.Bool("yes", false, "With --delete, do not ask for confirmation")
+	insert := fs.Bool("insert", false, "With run, print the final command for a shell key binding instead of running it")
+	history := fs.Bool("history", false, "With run, append the final command to the shell history instead of running it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: memo %s [flags] [query...]\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
 
-	// Ensure the API URL ends with `/api/memos`
-	if !strings.HasSuffix(apiURL, "/api/v1/memos") {
-		apiURL = strings.TrimRight(apiURL, "/") + "/api/v1/memos"
+	switch *format {
+	case "table", "plain", "json", "ndjson", "fzf", "script", "sunbeam":
+	default:
+		log.Fatalf("Error: unknown format %q", *format)
+	}
+	if err := sortEntries(nil, *sortBy); err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	// Split the tags into a slice
-	tagList := strings.Split(*tags, ",")
-	// Format tags into query parameter
-	formattedTags := fmt.Sprintf("tag_search==['%s']", strings.Join(tagList, "','"))
+	c, _, err := newClient(profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Construct the full URL with the tag filter
-	url := fmt.Sprintf("%s?filter=%s", apiURL, formattedTags)
-	//fmt.Println(url)
+	q, err := query.Parse(strings.TrimSpace(*queryFlag + " " + query.JoinArgs(fs.Args())))
+	if err != nil {
+		log.Fatalf("Error: invalid query: %v", err)
+	}
+
+	if *deleteName != "" {
+		if !*yes && !confirmDelete(*deleteName) {
+			fmt.Println("Cancelled")
+			return
+		}
+		if err := c.Delete(context.Background(), *deleteName); err != nil {
+			log.Fatalf("Error deleting memo: %v", err)
+		}
+		if err := cache.Forget(*deleteName); err != nil {
+			log.Printf("Warning: could not remove deleted memo from cache: %v", err)
+		}
+		fmt.Printf("Deleted %s\n", *deleteName)
+		return
+	}
 
-	memos, err := getMemos(apiKey, url)
+	filter := client.TagFilter(strings.Split(*tags, ","))
+	// The server order is the final order when no search word scores the
+	// matches, so reading can stop once the limit is reached
+	var enough func(page []client.Memo) bool
+	if *limit > 0 && *sortBy == "relevance" && !q.Scored() {
+		matched := 0
+		enough = func(page []client.Memo) bool {
+			matched += len(matchEntries(c, page, q, *lang))
+			return matched >= *limit
+		}
+	}
+	memos, err := fetchMemos(c, filter, *pageSize, enough, *offline, *refresh, *noCache)
 	if err != nil {
 		log.Fatalf("Error retrieving memos: %v", err)
 	}
 
-	stringsMap := []map[string]string{}
-	for _, memo := range memos {
-		codeBlock := extractCodeBlock(memo.Content)
-		tags := extractTags(memo.Content)
-		itemMap := map[string]string{"cmd": codeBlock, "tags": strings.Join(tags, " ")}
-		stringsMap = append(stringsMap, itemMap)
+	entries := matchEntries(c, memos, q, *lang)
+	// Without search words every score is equal and relevance keeps the
+	// server order
+	if err := sortEntries(entries, *sortBy); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
 	}
 
-	jsonData, err := json.MarshalIndent(stringsMap, "", "  ")
-	if err != nil {
-		log.Fatalf("Error converting to JSON: %v", err)
+	if runMode {
+		if err := runSaved(entries, *insert, *history); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if *format == "sunbeam" {
+		jsonData, err := json.Marshal(sunbeamList(entries, profile))
+		if err != nil {
+			log.Fatalf("Error converting to JSON: %v", err)
+		}
+		fmt.Println(string(jsonData))
+		return
 	}
 
-	// Print JSON to console
-	fmt.Println(string(jsonData))
+	width := 80
+	if *format == "table" {
+		width = terminalWidth()
+	}
+	if err := writeEntries(os.Stdout, *format, entries, width); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 }
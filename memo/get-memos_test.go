@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"memo/cache"
+	"memo/client"
+	"memo/sunbeam"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteCommand(t *testing.T) {
+	tests := []struct {
+		self, profile, name string
+		want                string
+	}{
+		{"/usr/bin/memo", "", "memos/1", "'/usr/bin/memo' list --delete 'memos/1'"},
+		{"/usr/bin/memo", "work", "memos/1", "'/usr/bin/memo' --profile 'work' list --delete 'memos/1'"},
+		{"/usr/bin/get-memos", "work", "memos/2", "'/usr/bin/get-memos' --profile 'work' --delete 'memos/2'"},
+	}
+	for _, tt := range tests {
+		if got := deleteCommand(tt.self, tt.profile, tt.name); got != tt.want {
+			t.Errorf("deleteCommand(%q, %q, %q) = %q, want %q", tt.self, tt.profile, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSunbeamListDeleteProfile(t *testing.T) {
+	entries := []commandEntry{{Cmd: "ls", Tags: "cmd", Name: "memos/7", Visibility: "private"}}
+	list := sunbeamList(entries, "work")
+	var command string
+	for _, action := range list.Items[0].Actions {
+		if action.Title == "Delete Memo" {
+			command = action.Command
+		}
+	}
+	if want := " --profile 'work' list --delete 'memos/7'"; !strings.HasSuffix(command, want) {
+		t.Errorf("delete action runs %q, want it to end with %q", command, want)
+	}
+}
+
+func TestFetchMemosStopsEarly(t *testing.T) {
+	srv, c := newTestServer(t)
+	for i := range 120 {
+		srv.AddMemo(fmt.Sprintf("```shell\necho %d\n```\n\n**Tags:**\n#cmd", i), "")
+	}
+	pages := 0
+	enough := func(page []client.Memo) bool {
+		pages++
+		return true
+	}
+
+	// A full sync is due, so the server is read directly up to the limit
+	memos, err := fetchMemos(c, "", 50, enough, false, false, false)
+	if err != nil {
+		t.Fatalf("fetchMemos: %v", err)
+	}
+	if len(memos) != 50 || pages != 1 || len(srv.Requests()) != 1 {
+		t.Errorf("got %d memos from %d pages and %d requests, want one page of 50", len(memos), pages, len(srv.Requests()))
+	}
+	memoCache, err := cache.Load(c.BaseURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !memoCache.SyncedAt.IsZero() {
+		t.Error("reading up to the limit filled the cache")
+	}
+
+	// Once synced, the cache answers after an incremental sync
+	if _, err := fetchMemos(c, "", 50, nil, false, false, false); err != nil {
+		t.Fatalf("fetchMemos: %v", err)
+	}
+	before := len(srv.Requests())
+	memos, err = fetchMemos(c, "", 50, enough, false, false, false)
+	if err != nil {
+		t.Fatalf("fetchMemos: %v", err)
+	}
+	if len(memos) != 120 || len(srv.Requests())-before != 1 {
+		t.Errorf("got %d memos with %d requests, want the 120 cached after one request", len(memos), len(srv.Requests())-before)
+	}
+}
+
+func TestPickEntry(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	entries := []commandEntry{{Cmd: "ls"}, {Cmd: "ls -la"}, {Cmd: "ls -R"}}
+	tests := []struct {
+		name    string
+		entries []commandEntry
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"single", entries[:1], "", "ls", false},
+		{"default", entries, "\n", "ls", false},
+		{"number", entries, "2\n", "ls -la", false},
+		{"out of range then number", entries, "9\nx\n3\n", "ls -R", false},
+		{"number at EOF", entries, "3", "ls -R", false},
+		{"EOF", entries, "", "", true},
+		{"invalid at EOF", entries, "x", "", true},
+		{"none", nil, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickEntry(bufio.NewReader(strings.NewReader(tt.input)), io.Discard, tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pickEntry() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got.Cmd != tt.want {
+				t.Errorf("pickEntry() = %q, want %q", got.Cmd, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptParams(t *testing.T) {
+	names := []string{"namespace", "pod"}
+	defaults := map[string]string{"namespace": "prod"}
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"remembered default", "\napi\n", map[string]string{"namespace": "prod", "pod": "api"}, false},
+		{"override default", "dev\napi\n", map[string]string{"namespace": "dev", "pod": "api"}, false},
+		{"required asked again", "\n\napi\n", map[string]string{"namespace": "prod", "pod": "api"}, false},
+		{"value at EOF", "\napi", map[string]string{"namespace": "prod", "pod": "api"}, false},
+		{"spaces kept", "\n my pod \r\n", map[string]string{"namespace": "prod", "pod": " my pod "}, false},
+		{"EOF without value", "\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promptParams(bufio.NewReader(strings.NewReader(tt.input)), io.Discard, names, defaults)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("promptParams() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("promptParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveParamDefaults(t *testing.T) {
+	stateHome := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateHome)
+	if got := loadParamDefaults(); len(got) != 0 {
+		t.Fatalf("loadParamDefaults() without a file = %v", got)
+	}
+
+	want := map[string]string{"namespace": "prod", "pod": "api"}
+	if err := saveParamDefaults(want); err != nil {
+		t.Fatalf("saveParamDefaults: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateHome, "memo", "params.json")); err != nil {
+		t.Errorf("params file not in the state directory: %v", err)
+	}
+	if got := loadParamDefaults(); !maps.Equal(got, want) {
+		t.Errorf("loadParamDefaults() = %v, want %v", got, want)
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	timestamp := regexp.MustCompile(`(?m)^: \d+:0;`)
+	tests := []struct {
+		file    string
+		command string
+		want    string
+	}{
+		{".bash_history", "kubectl get pods", "kubectl get pods\n"},
+		{".zsh_history", "kubectl get pods", ": TIME:0;kubectl get pods\n"},
+		{".zsh_history", "for f in *; do\n  echo $f\ndone", ": TIME:0;for f in *; do\\\n  echo $f\\\ndone\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		t.Setenv("HISTFILE", path)
+		if err := appendHistory(tt.command); err != nil {
+			t.Fatalf("appendHistory: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := timestamp.ReplaceAllString(string(data), ": TIME:0;"); got != tt.want {
+			t.Errorf("%s after appendHistory(%q) = %q, want %q", tt.file, tt.command, got, tt.want)
+		}
+	}
+}
+
+// testEntries are commands of three memos, as listed by the server.
+var testEntries = []commandEntry{
+	{
+		Cmd: "kubectl get pods", Lang: "shell", Tags: "cmd k8s", Name: "memos/12", Visibility: "private", Pinned: true,
+		URL:        "https://memos.example/m/12",
+		CreateTime: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC), UpdateTime: time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		Cmd: "docker build \\\n  -t app .", Tags: "cmd docker", Name: "memos/3", Visibility: "public",
+		URL:        "https://memos.example/m/3",
+		CreateTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), UpdateTime: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		Cmd: "SELECT count(*) FROM users WHERE created_at > now() - interval '1 day';\nSELECT 1;", Lang: "sql", Tags: "db", Name: "memos/100", Visibility: "protected",
+		URL:        "https://memos.example/m/100",
+		CreateTime: time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC), UpdateTime: time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestWriteEntries(t *testing.T) {
+	exportDate := regexp.MustCompile(`on \d{4}-\d{2}-\d{2}\n`)
+	tests := []struct {
+		format string
+		width  int
+		want   string
+	}{
+		{"plain", 80, `kubectl get pods
+docker build \
+  -t app .
+SELECT count(*) FROM users WHERE created_at > now() - interval '1 day';
+SELECT 1;
+`},
+		{"fzf", 80, "kubectl get pods\tcmd k8s\tmemos/12\thttps://memos.example/m/12\n" +
+			"docker build -t app .\tcmd docker\tmemos/3\thttps://memos.example/m/3\n" +
+			"SELECT count(*) FROM users WHERE created_at > now() - interval '1 day'; SELECT 1;\tdb\tmemos/100\thttps://memos.example/m/100\n"},
+		{"ndjson", 80, `{"cmd":"kubectl get pods","lang":"shell","tags":"cmd k8s","name":"memos/12","createTime":"2024-01-10T12:00:00Z","updateTime":"2024-01-20T12:00:00Z","visibility":"private","pinned":true,"url":"https://memos.example/m/12"}
+{"cmd":"docker build \\\n  -t app .","tags":"cmd docker","name":"memos/3","createTime":"2024-03-01T12:00:00Z","updateTime":"2024-03-05T12:00:00Z","visibility":"public","pinned":false,"url":"https://memos.example/m/3"}
+{"cmd":"SELECT count(*) FROM users WHERE created_at \u003e now() - interval '1 day';\nSELECT 1;","lang":"sql","tags":"db","name":"memos/100","createTime":"2023-12-24T12:00:00Z","updateTime":"2024-02-02T12:00:00Z","visibility":"protected","pinned":false,"url":"https://memos.example/m/100"}
+`},
+		{"table", 80, `ID    UPDATED     COMMAND                                             TAGS
+12*   2024-01-20  kubectl get pods                                    cmd k8s
+3     2024-03-05  docker build -t app .                               cmd docker
+100   2024-02-02  SELECT count(*) FROM users WHERE created_at > now…  db
+`},
+		{"table", 50, `ID    UPDATED     COMMAND               TAGS
+12*   2024-01-20  kubectl get pods      cmd k8s
+3     2024-03-05  docker build -t app…  cmd docker
+100   2024-02-02  SELECT count(*) FRO…  db
+`},
+		{"script", 80, `#!/bin/sh
+# Command memos exported by get-memos on DATE
+
+# memos/12 (private, updated 2024-01-20) https://memos.example/m/12
+# #cmd #k8s
+kubectl get pods
+
+# memos/3 (public, updated 2024-03-05) https://memos.example/m/3
+# #cmd #docker
+docker build \
+  -t app .
+
+# memos/100 (protected, updated 2024-02-02) https://memos.example/m/100
+# #db
+# [sql]
+# SELECT count(*) FROM users WHERE created_at > now() - interval '1 day';
+# SELECT 1;
+`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := writeEntries(&b, tt.format, testEntries, tt.width); err != nil {
+			t.Fatalf("writeEntries(%s): %v", tt.format, err)
+		}
+		if got := exportDate.ReplaceAllString(b.String(), "on DATE\n"); got != tt.want {
+			t.Errorf("writeEntries(%s, width %d) =\n%s\nwant\n%s", tt.format, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestWriteEntriesJSON(t *testing.T) {
+	var b strings.Builder
+	if err := writeEntries(&b, "json", testEntries, 80); err != nil {
+		t.Fatal(err)
+	}
+	var got []commandEntry
+	if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+		t.Fatalf("json output does not decode: %v\n%s", err, b.String())
+	}
+	if !reflect.DeepEqual(got, testEntries) {
+		t.Errorf("json output decodes to %+v, want %+v", got, testEntries)
+	}
+	if err := writeEntries(&b, "yaml", testEntries, 80); err == nil {
+		t.Error("writeEntries(yaml) succeeded")
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{"relevance", []string{"memos/12", "memos/3", "memos/100"}},
+		{"recent", []string{"memos/3", "memos/100", "memos/12"}},
+		{"created", []string{"memos/3", "memos/12", "memos/100"}},
+		{"pinned", []string{"memos/12", "memos/3", "memos/100"}},
+	}
+	for _, tt := range tests {
+		entries := slices.Clone(testEntries)
+		if err := sortEntries(entries, tt.by); err != nil {
+			t.Fatalf("sortEntries(%s): %v", tt.by, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sortEntries(%s) = %q, want %q", tt.by, got, tt.want)
+		}
+	}
+
+	// Better matches go first, ties keep the server order
+	entries := slices.Clone(testEntries)
+	entries[1].score, entries[2].score = 10, 10
+	sortEntries(entries, "relevance")
+	if entries[0].Name != "memos/3" || entries[1].Name != "memos/100" {
+		t.Errorf("relevance order = %s, %s, %s", entries[0].Name, entries[1].Name, entries[2].Name)
+	}
+	if err := sortEntries(entries, "name"); err == nil {
+		t.Error("sortEntries(name) succeeded")
+	}
+}
+
+func TestSingleLine(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"ls -la", "ls -la"},
+		{"cd /tmp\nls", "cd /tmp; ls"},
+		{"docker run \\\n  --rm \\\n  alpine", "docker run --rm alpine"},
+		{"SELECT 1;\nSELECT 2;", "SELECT 1; SELECT 2;"},
+		{"for f in *; do\n  echo $f\ndone", "for f in *; do echo $f; done"},
+		{"ps aux |\ngrep ssh", "ps aux | grep ssh"},
+		{"cd /tmp\n\nls", "cd /tmp; ls"},
+	}
+	for _, tt := range tests {
+		if got := singleLine(tt.command); got != tt.want {
+			t.Errorf("singleLine(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"kubectl", 10, "kubectl"},
+		{"kubectl", 7, "kubectl"},
+		{"kubectl", 5, "kube…"},
+		{"kubectl", 1, "k"},
+		{"kubectl", 0, ""},
+		{"größer als", 6, "größe…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSunbeamList(t *testing.T) {
+	extensionPreferences = &sunbeam.Preferences{}
+	t.Cleanup(func() { extensionPreferences = nil })
+
+	data, err := json.Marshal(sunbeamList(testEntries, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page struct {
+		Type  string
+		Items []struct {
+			Title       string
+			Subtitle    string
+			Accessories []string
+			Actions     []map[string]any
+		}
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Type != "list" || len(page.Items) != 3 {
+		t.Fatalf("page of type %q with %d items, want a list of 3", page.Type, len(page.Items))
+	}
+
+	want := []struct {
+		title, subtitle string
+		accessories     []string
+	}{
+		{"kubectl get pods", "#cmd #k8s", []string{"pinned", "shell", "private", "2024-01-20"}},
+		{"docker build \\ …", "#cmd #docker", []string{"public", "2024-03-05"}},
+		{"SELECT count(*) FROM users WHERE created_at > now() - interval '1 day'; …", "#db", []string{"sql", "protected", "2024-02-02"}},
+	}
+	for i, item := range page.Items {
+		if item.Title != want[i].title || item.Subtitle != want[i].subtitle || !slices.Equal(item.Accessories, want[i].accessories) {
+			t.Errorf("item %d = %q, %q, %q, want %q, %q, %q", i, item.Title, item.Subtitle, item.Accessories,
+				want[i].title, want[i].subtitle, want[i].accessories)
+		}
+	}
+
+	var actions []string
+	for _, action := range page.Items[1].Actions {
+		actions = append(actions, fmt.Sprintf("%s %s", action["type"], action["title"]))
+	}
+	wantActions := []string{"copy Copy Command", "exec Run in Terminal", "open Open Memo", "copy Copy Memo Link", "run Delete Memo"}
+	if !slices.Equal(actions, wantActions) {
+		t.Errorf("actions = %q, want %q", actions, wantActions)
+	}
+	docker := page.Items[1].Actions
+	if docker[0]["text"] != testEntries[1].Cmd || docker[1]["command"] != testEntries[1].Cmd || docker[2]["url"] != testEntries[1].URL {
+		t.Errorf("actions do not carry the command and URL: %v", docker)
+	}
+	if del := docker[4]; del["command"] != "delete" || del["reload"] != true || !reflect.DeepEqual(del["params"], map[string]any{"name": "memos/3"}) {
+		t.Errorf("delete action = %v, want the delete command for memos/3", del)
+	}
+}
+
+func TestConfirmNo(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" yes ", true},
+		{"\n", false},
+		{"n\n", false},
+		{"", false},
+		{"sure\n", false},
+	}
+	for _, tt := range tests {
+		if got := confirmNo(bufio.NewReader(strings.NewReader(tt.input)), io.Discard, "Delete?"); got != tt.want {
+			t.Errorf("confirmNo(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
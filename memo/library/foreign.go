@@ -0,0 +1,107 @@
+package library
+
+import (
+	"fmt"
+	"io/fs"
+	"memo/snippet"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Variable is a parameter of an imported command and where its values come
+// from in the source format: a default, an example or a command listing
+// the choices.
+type Variable struct {
+	Name        string
+	Description string
+}
+
+// commandSnippet builds a memo formatted the way post-memo writes them: the
+// description, a fenced shell block, the variables and the **Tags:**
+// section. The cmd tag is always added.
+func commandSnippet(description, command string, vars []Variable, tags []string, output string) Snippet {
+	var b strings.Builder
+	if description = strings.TrimSpace(description); description != "" {
+		b.WriteString(description + "\n\n")
+	}
+	b.WriteString(snippet.Fenced("shell", strings.TrimRight(command, "\n")))
+	if len(vars) > 0 {
+		b.WriteString("\n\n**Variables:**")
+		for _, v := range vars {
+			fmt.Fprintf(&b, "\n- `%s`", v.Name)
+			if v.Description != "" {
+				b.WriteString(": " + v.Description)
+			}
+		}
+	}
+	if output = strings.TrimRight(output, "\n"); output != "" {
+		fmt.Fprintf(&b, "\n\n<details><summary>Output</summary>\n\n%s\n\n</details>", snippet.Fenced("", output))
+	}
+
+	allTags := []string{"cmd"}
+	for _, tag := range tags {
+		if tag = snippet.NormalizeTag(tag); tag != "" && !slices.Contains(allTags, tag) {
+			allTags = append(allTags, tag)
+		}
+	}
+	return Snippet{Tags: allTags, Content: snippet.ReplaceTags(b.String(), allTags)}
+}
+
+// readFiles reads path, or every file below it with the given extension
+// when it is a directory, calling parse with each file's content.
+func readFiles(path, ext string, parse func(name, data string) ([]Snippet, error)) ([]Snippet, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	if info.IsDir() {
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(p, ext) {
+				paths = append(paths, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		paths = []string{path}
+	}
+
+	var snippets []Snippet
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parse(strings.TrimSuffix(filepath.Base(p), ext), strings.ReplaceAll(string(data), "\r\n", "\n"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		snippets = append(snippets, parsed...)
+	}
+	return snippets, nil
+}
+
+// Formats lists the formats ReadFormat understands.
+var Formats = []string{"library", "pet", "navi", "tldr"}
+
+// ReadFormat loads snippets from path in one of Formats: an exported
+// library, a pet snippet.toml, navi .cheat files or tldr pages. Directories
+// are searched recursively for navi and tldr files.
+func ReadFormat(format, path string) ([]Snippet, error) {
+	switch format {
+	case "library":
+		return Read(path)
+	case "pet":
+		return readFiles(path, ".toml", func(_, data string) ([]Snippet, error) { return ParsePet(data) })
+	case "navi":
+		return readFiles(path, ".cheat", func(_, data string) ([]Snippet, error) { return ParseNavi(data), nil })
+	case "tldr":
+		return readFiles(path, ".md", func(name, data string) ([]Snippet, error) { return ParseTldr(name, data), nil })
+	}
+	return nil, fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(Formats, ", "))
+}
@@ -0,0 +1,195 @@
+package library
+
+import (
+	"memo/snippet"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const petTOML = `# pet snippets
+[[snippets]]
+  description = "Show pod logs"
+  command = "kubectl -n <namespace=default> logs <pod> --since=<since=1h>"
+  tag = ["k8s", "Kubectl"]
+  output = ""
+
+[[snippets]]
+  description = 'literal \n string'
+  command = """
+for f in *.log; do
+  gzip "$f"
+done"""
+  tag = [
+    "files", # trailing comment
+  ]
+  output = '''
+compressed'''
+
+[other]
+  command = "ignored"
+`
+
+func TestParsePet(t *testing.T) {
+	got, err := ParsePet(petTOML)
+	if err != nil {
+		t.Fatalf("ParsePet: %v", err)
+	}
+	want := []Snippet{
+		{
+			Tags: []string{"cmd", "k8s", "kubectl"},
+			Content: "Show pod logs\n\n```shell\nkubectl -n <namespace> logs <pod> --since=<since>\n```\n\n" +
+				"**Variables:**\n- `namespace`: default `default`\n- `pod`\n- `since`: default `1h`\n\n**Tags:**\n#cmd #k8s #kubectl",
+		},
+		{
+			Tags: []string{"cmd", "files"},
+			Content: "literal \\n string\n\n```shell\nfor f in *.log; do\n  gzip \"$f\"\ndone\n```\n\n" +
+				"<details><summary>Output</summary>\n\n```\ncompressed\n```\n\n</details>\n\n**Tags:**\n#cmd #files",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePet =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParsePetOutputWithFence(t *testing.T) {
+	got, err := ParsePet(`[[snippets]]
+  description = "Render the README"
+  command = "glow README.md"
+  output = """
+` + "```go" + `
+fmt.Println()
+` + "```" + `
+</details>"""
+`)
+	if err != nil {
+		t.Fatalf("ParsePet: %v", err)
+	}
+	want := "Render the README\n\n```shell\nglow README.md\n```\n\n" +
+		"<details><summary>Output</summary>\n\n````\n```go\nfmt.Println()\n```\n</details>\n````\n\n</details>\n\n**Tags:**\n#cmd"
+	if len(got) != 1 || got[0].Content != want {
+		t.Fatalf("ParsePet =\n%+v\nwant content\n%s", got, want)
+	}
+	// The output block stays one block, hiding the fence from the command
+	blocks := snippet.ExtractCodeBlocks(got[0].Content)
+	if len(blocks) != 2 || blocks[0].Code != "glow README.md" || !blocks[1].InDetails {
+		t.Errorf("code blocks = %+v, want the command and the output", blocks)
+	}
+}
+
+func TestParsePetErrors(t *testing.T) {
+	for _, data := range []string{
+		"[[snippets]]\ncommand = \"unterminated\n",
+		"[[snippets]]\ncommand = \"\"\"never closed",
+		"[[snippets]]\ncommand \"no equals\"",
+		"[[snippets]]\ntag = [1, 2]",
+		"[[snippets]]\ncommand = \"bad \\q escape\"",
+	} {
+		if _, err := ParsePet(data); err == nil {
+			t.Errorf("ParsePet(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+const naviCheat = `% git, code
+
+# Change branch
+git checkout <branch>
+
+; a comment
+# Delete a branch
+git branch -d <branch>
+
+$ branch: git branch | awk '{print $NF}' --- --column 2
+
+% docker
+
+# Follow logs
+docker logs -f \
+  <container>
+`
+
+func TestParseNavi(t *testing.T) {
+	got := ParseNavi(naviCheat)
+	want := []Snippet{
+		{
+			Tags: []string{"cmd", "git", "code"},
+			Content: "Change branch\n\n```shell\ngit checkout <branch>\n```\n\n" +
+				"**Variables:**\n- `branch`: values from `git branch | awk '{print $NF}'`\n\n**Tags:**\n#cmd #git #code",
+		},
+		{
+			Tags: []string{"cmd", "git", "code"},
+			Content: "Delete a branch\n\n```shell\ngit branch -d <branch>\n```\n\n" +
+				"**Variables:**\n- `branch`: values from `git branch | awk '{print $NF}'`\n\n**Tags:**\n#cmd #git #code",
+		},
+		{
+			Tags:    []string{"cmd", "docker"},
+			Content: "Follow logs\n\n```shell\ndocker logs -f \\\n  <container>\n```\n\n**Variables:**\n- `container`\n\n**Tags:**\n#cmd #docker",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseNavi =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+const tldrPage = "# tar\n\n> Archiving utility.\n> More information: <https://www.gnu.org/software/tar>.\n\n" +
+	"- [c]reate an archive from files:\n\n`tar cf {{target.tar}} {{file1}} {{file2}}`\n\n" +
+	"- E[x]tract an archive into a directory:\n\n`tar xf {{source.tar}} -C {{path/to/directory}} {{path/to/directory}}`\n"
+
+func TestParseTldr(t *testing.T) {
+	got := ParseTldr("ignored", tldrPage)
+	want := []Snippet{
+		{
+			Tags: []string{"cmd", "tar"},
+			Content: "[c]reate an archive from files\n\n```shell\ntar cf {{target_tar}} {{file1}} {{file2}}\n```\n\n" +
+				"**Variables:**\n- `target_tar`: e.g. `target.tar`\n- `file1`: e.g. `file1`\n- `file2`: e.g. `file2`\n\n**Tags:**\n#cmd #tar",
+		},
+		{
+			Tags: []string{"cmd", "tar"},
+			Content: "E[x]tract an archive into a directory\n\n```shell\ntar xf {{source_tar}} -C {{path_to_directory}} {{path_to_directory}}\n```\n\n" +
+				"**Variables:**\n- `source_tar`: e.g. `source.tar`\n- `path_to_directory`: e.g. `path/to/directory`\n\n**Tags:**\n#cmd #tar",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTldr =\n%+v\nwant\n%+v", got, want)
+	}
+
+	command, vars := tldrCommand("{{a.b}} {{a-b}} {{8080}}")
+	if command != "{{a_b}} {{a_b_2}} {{arg8080}}" || len(vars) != 3 {
+		t.Errorf("tldrCommand = %q, %+v", command, vars)
+	}
+}
+
+func TestReadFormat(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "common"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "common", "tar.md"), []byte(tldrPage), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "git.cheat"), []byte(naviCheat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   int
+	}{
+		{"tldr", 2},
+		{"navi", 3},
+	}
+	for _, tt := range tests {
+		snippets, err := ReadFormat(tt.format, dir)
+		if err != nil {
+			t.Fatalf("ReadFormat(%s): %v", tt.format, err)
+		}
+		if len(snippets) != tt.want {
+			t.Errorf("ReadFormat(%s) returned %d snippets, want %d", tt.format, len(snippets), tt.want)
+		}
+	}
+	if _, err := ReadFormat("cheat.sh", dir); err == nil {
+		t.Error("ReadFormat accepted an unknown format")
+	}
+}
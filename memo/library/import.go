@@ -0,0 +1,68 @@
+package library
+
+import (
+	"memo/client"
+	"strings"
+)
+
+// Import actions.
+const (
+	// ActionCreate creates a new memo.
+	ActionCreate = "create"
+	// ActionSkip leaves out a snippet already present with the same content.
+	ActionSkip = "skip"
+	// ActionConflict leaves out a snippet whose command is present with
+	// different content or tags.
+	ActionConflict = "conflict"
+)
+
+// ImportItem is what importing one snippet will do.
+type ImportItem struct {
+	Snippet Snippet
+	Action  string
+	// Existing is the memo, or earlier snippet, with the same command.
+	Existing string
+}
+
+// PlanImport decides for each snippet whether to create it, skip it as a
+// duplicate or report it as a conflict. Snippets are matched to existing
+// memos, and to earlier snippets of the same import, by CommandKey.
+// Snippets without a code block are always created.
+func PlanImport(snippets []Snippet, existing []client.Memo) []ImportItem {
+	type known struct {
+		name    string
+		content string
+	}
+	byKey := make(map[string]known)
+	for _, memo := range existing {
+		if key := CommandKey(memo.Content); key != "" {
+			if _, ok := byKey[key]; !ok {
+				byKey[key] = known{memo.Name, memo.Content}
+			}
+		}
+	}
+
+	items := make([]ImportItem, len(snippets))
+	for i, s := range snippets {
+		content := s.MemoContent()
+		key := CommandKey(content)
+		items[i] = ImportItem{Snippet: s, Action: ActionCreate}
+		if key == "" {
+			continue
+		}
+		if k, ok := byKey[key]; ok {
+			items[i].Existing = k.name
+			items[i].Action = ActionConflict
+			if strings.TrimSpace(k.content) == strings.TrimSpace(content) {
+				items[i].Action = ActionSkip
+			}
+			continue
+		}
+		name := s.Name
+		if name == "" {
+			name = s.FileName(i)
+		}
+		byKey[key] = known{name + " (earlier in this import)", content}
+	}
+	return items
+}
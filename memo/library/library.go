@@ -0,0 +1,199 @@
+// Package library converts command memos to and from a portable snippet
+// library: a directory of Markdown files with YAML front matter, or a single
+// JSON bundle. The library can be kept in git and imported into another
+// Memos instance.
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"memo/client"
+	"memo/snippet"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the version of the JSON bundle format.
+const BundleVersion = 1
+
+// Snippet is one exported memo.
+type Snippet struct {
+	// Name is the resource name the memo had on the server it came from.
+	Name       string    `json:"name,omitempty"`
+	Tags       []string  `json:"tags"`
+	Visibility string    `json:"visibility"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+	Content    string    `json:"content"`
+}
+
+// Bundle is the JSON export format.
+type Bundle struct {
+	Version  int       `json:"version"`
+	Source   string    `json:"source,omitempty"`
+	Exported time.Time `json:"exported"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// FromMemo returns the snippet for memo.
+func FromMemo(memo *client.Memo) Snippet {
+	return Snippet{
+		Name:       memo.Name,
+		Tags:       memo.TagList(),
+		Visibility: strings.ToLower(string(memo.Visibility)),
+		Created:    memo.CreateTime,
+		Updated:    memo.UpdateTime,
+		Content:    memo.Content,
+	}
+}
+
+// MemoContent returns the content to post for s. The tags listed in the
+// front matter win over the **Tags:** section of the content, so tags can be
+// edited in either place.
+func (s Snippet) MemoContent() string {
+	if len(s.Tags) == 0 || slices.Equal(snippet.ExtractTags(s.Content), s.Tags) {
+		return s.Content
+	}
+	return snippet.ReplaceTags(s.Content, s.Tags)
+}
+
+// CommandKey identifies the command of a memo for duplicate detection: its
+// first code block with runs of whitespace collapsed.
+func CommandKey(content string) string {
+	return strings.Join(strings.Fields(snippet.ExtractCodeBlock(content)), " ")
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// FileName returns the file name of s in a library directory, made of the
+// memo id and the start of its command, e.g. "12-kubectl-get-pods.md".
+func (s Snippet) FileName(index int) string {
+	id := strings.TrimPrefix(s.Name, "memos/")
+	if id == "" {
+		id = fmt.Sprint(index + 1)
+	}
+	slug := slugInvalidChars.ReplaceAllString(strings.ToLower(CommandKey(s.Content)), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	if slug == "" {
+		return id + ".md"
+	}
+	return id + "-" + slug + ".md"
+}
+
+// WriteDir writes every snippet to its own Markdown file in dir. Files
+// left by an earlier export of the same memo under another name, after its
+// command changed, are removed so the directory holds each memo once.
+func WriteDir(dir string, snippets []Snippet) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, s := range snippets {
+		name := s.FileName(i)
+		if err := removeStale(dir, s, name); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, MarshalMarkdown(s), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStale removes the files of an earlier export of the memo s other
+// than keep. Snippets without a memo name have no files to match.
+func removeStale(dir string, s Snippet, keep string) error {
+	id := strings.TrimPrefix(s.Name, "memos/")
+	if id == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, id+"-*.md"))
+	if err != nil {
+		return err
+	}
+	for _, path := range append(paths, filepath.Join(dir, id+".md")) {
+		if filepath.Base(path) == keep {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDir reads every Markdown file in dir, in file name order.
+func ReadDir(dir string) ([]Snippet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	var snippets []Snippet
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s, err := ParseMarkdown(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		snippets = append(snippets, s)
+	}
+	return snippets, nil
+}
+
+// MarshalBundle encodes snippets as a JSON bundle.
+func MarshalBundle(source string, snippets []Snippet) ([]byte, error) {
+	return json.MarshalIndent(Bundle{
+		Version:  BundleVersion,
+		Source:   source,
+		Exported: time.Now().UTC().Truncate(time.Second),
+		Snippets: snippets,
+	}, "", "  ")
+}
+
+// ParseBundle decodes a JSON bundle.
+func ParseBundle(data []byte) ([]Snippet, error) {
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return b.Snippets, nil
+}
+
+// Read loads snippets from a library directory, a JSON bundle or a single
+// Markdown file.
+func Read(path string) ([]Snippet, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadDir(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".json") {
+		return ParseBundle(data)
+	}
+	s, err := ParseMarkdown(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return []Snippet{s}, nil
+}
@@ -0,0 +1,182 @@
+package library
+
+import (
+	"memo/client"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testSnippet = Snippet{
+	Name:       "memos/12",
+	Tags:       []string{"cmd", "kubectl", "k8s/prod"},
+	Visibility: "private",
+	Created:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	Updated:    time.Date(2024, 5, 2, 8, 30, 0, 0, time.UTC),
+	Content:    "```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd #kubectl #k8s/prod",
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	odd := Snippet{Tags: []string{"a,b", "it's", " spaced"}, Content: "no code\n\nwith trailing newline\n"}
+	for _, s := range []Snippet{testSnippet, odd} {
+		got, err := ParseMarkdown(MarshalMarkdown(s))
+		if err != nil {
+			t.Fatalf("ParseMarkdown: %v", err)
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("round trip = %+v, want %+v", got, s)
+		}
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Snippet
+	}{
+		{
+			"block list and quotes",
+			"---\r\ntags:\r\n  - cmd\r\n  - 'git'\r\nvisibility: \"PUBLIC\"\r\nauthor: someone\r\n---\r\nbody",
+			Snippet{Tags: []string{"cmd", "git"}, Visibility: "public", Content: "body"},
+		},
+		{
+			"comments and blank lines",
+			"---\n# exported\n\ntags: [a, \"b\"]\n---\nbody",
+			Snippet{Tags: []string{"a", "b"}, Content: "body"},
+		},
+		{"no front matter", "```shell\nls\n```", Snippet{Content: "```shell\nls\n```"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMarkdown([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseMarkdown: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMarkdown = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMarkdownErrors(t *testing.T) {
+	for _, data := range []string{
+		"---\ntags: [a]\nbody",
+		"---\nnot a key\n---\n",
+		"---\ntags: [a, \"b]\n---\n",
+		"---\ncreated: yesterday\n---\n",
+	} {
+		if _, err := ParseMarkdown([]byte(data)); err == nil {
+			t.Errorf("ParseMarkdown(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestDirAndBundle(t *testing.T) {
+	second := Snippet{Tags: []string{"cmd"}, Visibility: "public", Content: "```sql\nSELECT 1;\n```"}
+	snippets := []Snippet{testSnippet, second}
+
+	dir := t.TempDir()
+	if err := WriteDir(dir, snippets); err != nil {
+		t.Fatalf("WriteDir: %v", err)
+	}
+	for _, name := range []string{"12-kubectl-get-pods.md", "2-select-1.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	fromDir, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read(dir): %v", err)
+	}
+	if !reflect.DeepEqual(fromDir, snippets) {
+		t.Errorf("Read(dir) = %+v, want %+v", fromDir, snippets)
+	}
+
+	data, err := MarshalBundle("https://memos.example.com", snippets)
+	if err != nil {
+		t.Fatalf("MarshalBundle: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fromBundle, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read(bundle): %v", err)
+	}
+	if !reflect.DeepEqual(fromBundle, snippets) {
+		t.Errorf("Read(bundle) = %+v, want %+v", fromBundle, snippets)
+	}
+
+	if _, err := ParseBundle([]byte(`{"version": 99, "snippets": []}`)); err == nil {
+		t.Error("ParseBundle accepted an unknown version")
+	}
+}
+
+func TestWriteDirReplacesRenamedFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Another memo whose id starts the same is left alone
+	other := Snippet{Name: "memos/120", Content: "```shell\nuptime\n```"}
+	if err := WriteDir(dir, []Snippet{testSnippet, other}); err != nil {
+		t.Fatalf("WriteDir: %v", err)
+	}
+
+	edited := testSnippet
+	edited.Content = "```shell\nkubectl get deployments\n```"
+	if err := WriteDir(dir, []Snippet{edited}); err != nil {
+		t.Fatalf("WriteDir: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"12-kubectl-get-deployments.md", "120-uptime.md"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %q, want %q", names, want)
+	}
+}
+
+func TestMemoContentUsesFrontMatterTags(t *testing.T) {
+	s := testSnippet
+	s.Tags = []string{"cmd", "k8s"}
+	want := "```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd #k8s"
+	if got := s.MemoContent(); got != want {
+		t.Errorf("MemoContent = %q, want %q", got, want)
+	}
+	if got := testSnippet.MemoContent(); got != testSnippet.Content {
+		t.Errorf("MemoContent changed content with matching tags: %q", got)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	existing := []client.Memo{
+		{Name: "memos/1", Content: "```shell\nls   -la\n```\n\n**Tags:**\n#cmd #ls"},
+		{Name: "memos/2", Content: "```shell\ngit status\n```\n\n**Tags:**\n#cmd"},
+	}
+	snippets := []Snippet{
+		{Content: "```shell\nls -la\n```\n\n**Tags:**\n#cmd #ls"},            // same command, different spacing
+		{Tags: []string{"cmd", "git"}, Content: "```shell\ngit status\n```"}, // same command, other tags
+		{Content: "```shell\ndf -h\n```"},
+		{Content: "```shell\ndf -h\n```"},
+		{Content: "```shell\ndf   -h\n```\nnotes"},
+		{Content: "a note without code"},
+	}
+	var got []string
+	for _, item := range PlanImport(snippets, existing) {
+		got = append(got, item.Action)
+	}
+	want := []string{ActionConflict, ActionConflict, ActionCreate, ActionSkip, ActionConflict, ActionCreate}
+	if !slices.Equal(got, want) {
+		t.Errorf("actions = %q, want %q", got, want)
+	}
+}
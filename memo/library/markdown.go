@@ -0,0 +1,191 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frontMatterDelim opens and closes the YAML front matter of a file.
+const frontMatterDelim = "---"
+
+// MarshalMarkdown encodes s as Markdown with YAML front matter:
+//
+//	---
+//	name: memos/12
+//	tags: [cmd, kubectl]
+//	visibility: private
+//	created: 2024-05-01T10:00:00Z
+//	updated: 2024-05-02T08:30:00Z
+//	---
+//	```shell
+//	kubectl get pods
+//	```
+func MarshalMarkdown(s Snippet) []byte {
+	var b strings.Builder
+	b.WriteString(frontMatterDelim + "\n")
+	if s.Name != "" {
+		fmt.Fprintf(&b, "name: %s\n", yamlString(s.Name))
+	}
+	quoted := make([]string, len(s.Tags))
+	for i, tag := range s.Tags {
+		quoted[i] = yamlString(tag)
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(quoted, ", "))
+	if s.Visibility != "" {
+		fmt.Fprintf(&b, "visibility: %s\n", yamlString(s.Visibility))
+	}
+	if !s.Created.IsZero() {
+		fmt.Fprintf(&b, "created: %s\n", s.Created.UTC().Format(time.RFC3339))
+	}
+	if !s.Updated.IsZero() {
+		fmt.Fprintf(&b, "updated: %s\n", s.Updated.UTC().Format(time.RFC3339))
+	}
+	b.WriteString(frontMatterDelim + "\n")
+	// The newline ending the file is not part of the content
+	b.WriteString(s.Content + "\n")
+	return []byte(b.String())
+}
+
+// ParseMarkdown decodes a file written by MarshalMarkdown. Only the subset
+// of YAML it writes is understood: scalars, flow lists ([a, b]) and block
+// lists ("- a" lines). A file without front matter is taken as content only.
+func ParseMarkdown(data []byte) (Snippet, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	head, rest, ok := strings.Cut(text, "\n")
+	if !ok || strings.TrimSpace(head) != frontMatterDelim {
+		return Snippet{Content: text}, nil
+	}
+
+	var s Snippet
+	lines := strings.Split(rest, "\n")
+	end := -1
+	var listKey string
+	for i, line := range lines {
+		if strings.TrimSpace(line) == frontMatterDelim {
+			end = i
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, isItem := strings.CutPrefix(trimmed, "- "); isItem && listKey != "" {
+			if listKey == "tags" {
+				s.Tags = append(s.Tags, yamlUnquote(item))
+			}
+			continue
+		}
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			return Snippet{}, fmt.Errorf("front matter line %d: expected key: value", i+2)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		listKey = ""
+		if value == "" {
+			listKey = key
+			continue
+		}
+		if err := s.setField(key, value); err != nil {
+			return Snippet{}, fmt.Errorf("front matter line %d: %w", i+2, err)
+		}
+	}
+	if end < 0 {
+		return Snippet{}, errors.New("front matter is not closed with ---")
+	}
+	s.Content = strings.TrimSuffix(strings.Join(lines[end+1:], "\n"), "\n")
+	return s, nil
+}
+
+// setField stores one front matter value. Unknown keys are ignored.
+func (s *Snippet) setField(key, value string) error {
+	var err error
+	switch key {
+	case "name":
+		s.Name = yamlUnquote(value)
+	case "visibility":
+		s.Visibility = strings.ToLower(yamlUnquote(value))
+	case "tags":
+		s.Tags, err = yamlFlowList(value)
+	case "created":
+		s.Created, err = time.Parse(time.RFC3339, yamlUnquote(value))
+	case "updated":
+		s.Updated, err = time.Parse(time.RFC3339, yamlUnquote(value))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// yamlString quotes s when it would not read back as the same plain scalar.
+func yamlString(s string) string {
+	if s == "" || strings.ContainsAny(s, ":#[]{},&*!|>'\"%@`") || strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// yamlUnquote returns the value of a plain, single or double quoted scalar.
+func yamlUnquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			if unquoted, err := strconv.Unquote(s); err == nil {
+				return unquoted
+			}
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
+
+// yamlFlowList parses [a, "b", 'c'] into its items.
+func yamlFlowList(s string) ([]string, error) {
+	inner, ok := strings.CutPrefix(s, "[")
+	if !ok {
+		return nil, fmt.Errorf("expected [ at %q", s)
+	}
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return nil, fmt.Errorf("expected ] at %q", s)
+	}
+
+	var items []string
+	var item strings.Builder
+	var quote byte
+	flush := func() {
+		if v := yamlUnquote(item.String()); v != "" {
+			items = append(items, v)
+		}
+		item.Reset()
+	}
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && i+1 < len(inner) {
+				item.WriteByte(c)
+				i++
+				c = inner[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			flush()
+			continue
+		}
+		item.WriteByte(c)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	flush()
+	return items, nil
+}
@@ -0,0 +1,84 @@
+package library
+
+import (
+	"memo/snippet"
+	"strings"
+)
+
+// naviCommand is one command of a navi cheatsheet section.
+type naviCommand struct {
+	description string
+	lines       []string
+}
+
+// ParseNavi converts a navi .cheat file. Each "% tag, tag" line starts a
+// section whose tags apply to its commands, a "# description" line names
+// the command below it, and "$ var: command" lines describe how the values
+// of a <var> are listed. Variables are added to the commands using them.
+func ParseNavi(data string) []Snippet {
+	var snippets []Snippet
+	var tags []string
+	var commands []naviCommand
+	vars := make(map[string]string)
+	// current is the index of the command being read, -1 between commands
+	current := -1
+	description := ""
+
+	flush := func() {
+		for _, c := range commands {
+			command := strings.Join(c.lines, "\n")
+			var used []Variable
+			for _, name := range snippet.Placeholders(command) {
+				v := Variable{Name: name}
+				if source, ok := vars[name]; ok {
+					v.Description = "values from `" + source + "`"
+				}
+				used = append(used, v)
+			}
+			snippets = append(snippets, commandSnippet(c.description, command, used, tags, ""))
+		}
+		commands = nil
+		vars = make(map[string]string)
+		current = -1
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			current = -1
+		case strings.HasPrefix(trimmed, "%"):
+			flush()
+			tags = nil
+			for _, tag := range strings.Split(strings.TrimPrefix(trimmed, "%"), ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			description = ""
+		case strings.HasPrefix(trimmed, "#"):
+			current = -1
+			description = strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+		case strings.HasPrefix(trimmed, "$"):
+			current = -1
+			name, source, ok := strings.Cut(strings.TrimPrefix(trimmed, "$"), ":")
+			if !ok {
+				continue
+			}
+			// fzf options follow ---
+			source, _, _ = strings.Cut(source, " --- ")
+			vars[strings.TrimSpace(name)] = strings.TrimSpace(source)
+		case strings.HasPrefix(trimmed, ";"), strings.HasPrefix(trimmed, "@"):
+			// Comments and extended cheatsheets are not commands
+		default:
+			if current < 0 {
+				commands = append(commands, naviCommand{description: description})
+				current = len(commands) - 1
+				description = ""
+			}
+			commands[current].lines = append(commands[current].lines, strings.TrimRight(line, " \t"))
+		}
+	}
+	flush()
+	return snippets
+}
@@ -0,0 +1,338 @@
+package library
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// petParam matches a pet parameter, <name> or <name=default>.
+var petParam = regexp.MustCompile(`<([A-Za-z_][\w-]*)(?:=([^<>]*))?>`)
+
+// ParsePet converts the [[snippets]] of a pet snippet.toml. Descriptions go
+// before the command, <name=default> parameters become <name> with the
+// default listed under **Variables:**, tags are kept and the recorded
+// output is added as an output section.
+func ParsePet(data string) ([]Snippet, error) {
+	tables, err := parseTOMLTables(data, "snippets")
+	if err != nil {
+		return nil, err
+	}
+
+	var snippets []Snippet
+	for _, t := range tables {
+		command, _ := t["command"].(string)
+		if strings.TrimSpace(command) == "" {
+			continue
+		}
+		description, _ := t["description"].(string)
+		output, _ := t["output"].(string)
+		tags, _ := t["tag"].([]string)
+
+		var vars []Variable
+		seen := make(map[string]bool)
+		for _, m := range petParam.FindAllStringSubmatch(command, -1) {
+			if seen[m[1]] {
+				continue
+			}
+			seen[m[1]] = true
+			v := Variable{Name: m[1]}
+			if m[2] != "" {
+				v.Description = "default `" + m[2] + "`"
+			}
+			vars = append(vars, v)
+		}
+		command = petParam.ReplaceAllString(command, "<$1>")
+
+		snippets = append(snippets, commandSnippet(description, command, vars, tags, output))
+	}
+	return snippets, nil
+}
+
+// tomlValue is a string, a []string or nil for values pet does not use.
+type tomlValue any
+
+// parseTOMLTables returns the key/value pairs of every [[name]] array table
+// in data. Only the TOML pet writes is understood: basic, literal and
+// multi-line strings, arrays of strings, and other scalars, which are
+// skipped. Keys outside the array tables are ignored.
+func parseTOMLTables(data, name string) ([]map[string]tomlValue, error) {
+	p := &tomlParser{data: data, line: 1}
+	var tables []map[string]tomlValue
+	var current map[string]tomlValue
+
+	for {
+		p.skipSpaceAndComments()
+		if p.eof() {
+			return tables, nil
+		}
+		if strings.HasPrefix(p.rest(), "[") {
+			header := p.readLine()
+			current = nil
+			if strings.TrimSpace(stripTOMLComment(header)) == "[["+name+"]]" {
+				current = make(map[string]tomlValue)
+				tables = append(tables, current)
+			}
+			continue
+		}
+
+		key, err := p.readKey()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.readValue()
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			current[key] = value
+		}
+	}
+}
+
+// stripTOMLComment removes a trailing comment from a header line.
+func stripTOMLComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+type tomlParser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) eof() bool    { return p.pos >= len(p.data) }
+func (p *tomlParser) rest() string { return p.data[p.pos:] }
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) advance(n int) {
+	p.line += strings.Count(p.data[p.pos:p.pos+n], "\n")
+	p.pos += n
+}
+
+// skipSpaceAndComments skips whitespace, newlines and # comments.
+func (p *tomlParser) skipSpaceAndComments() {
+	for !p.eof() {
+		switch c := p.data[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.advance(1)
+		case c == '#':
+			end := strings.IndexByte(p.rest(), '\n')
+			if end < 0 {
+				end = len(p.rest())
+			}
+			p.advance(end)
+		default:
+			return
+		}
+	}
+}
+
+// skipInlineSpace skips spaces and tabs on the current line.
+func (p *tomlParser) skipInlineSpace() {
+	for !p.eof() && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t') {
+		p.advance(1)
+	}
+}
+
+// readLine returns the rest of the current line.
+func (p *tomlParser) readLine() string {
+	end := strings.IndexByte(p.rest(), '\n')
+	if end < 0 {
+		end = len(p.rest())
+	}
+	line := p.rest()[:end]
+	p.advance(end)
+	return line
+}
+
+// readKey reads a bare or quoted key and the = after it.
+func (p *tomlParser) readKey() (string, error) {
+	var key string
+	if c := p.data[p.pos]; c == '"' || c == '\'' {
+		s, err := p.readString()
+		if err != nil {
+			return "", err
+		}
+		key = s
+	} else {
+		start := p.pos
+		for !p.eof() {
+			c := p.data[p.pos]
+			if !(c == '_' || c == '-' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				break
+			}
+			p.advance(1)
+		}
+		key = p.data[start:p.pos]
+		if key == "" {
+			return "", p.errorf("expected a key")
+		}
+	}
+	p.skipInlineSpace()
+	if p.eof() || p.data[p.pos] != '=' {
+		return "", p.errorf("expected = after %s", key)
+	}
+	p.advance(1)
+	p.skipInlineSpace()
+	return key, nil
+}
+
+// readValue reads a string, an array of strings or another scalar.
+func (p *tomlParser) readValue() (tomlValue, error) {
+	if p.eof() {
+		return nil, p.errorf("missing value")
+	}
+	switch p.data[p.pos] {
+	case '"', '\'':
+		return p.readString()
+	case '[':
+		return p.readArray()
+	}
+	// Numbers, booleans and dates end at the line or a comment
+	line := p.readLine()
+	if strings.TrimSpace(stripTOMLComment(line)) == "" {
+		return nil, p.errorf("missing value")
+	}
+	return nil, nil
+}
+
+// readArray reads an array of strings, possibly spanning lines.
+func (p *tomlParser) readArray() ([]string, error) {
+	p.advance(1)
+	var items []string
+	for {
+		p.skipSpaceAndComments()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		switch p.data[p.pos] {
+		case ']':
+			p.advance(1)
+			return items, nil
+		case ',':
+			p.advance(1)
+			continue
+		case '"', '\'':
+			s, err := p.readString()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, s)
+		default:
+			return nil, p.errorf("only arrays of strings are supported")
+		}
+	}
+}
+
+// readString reads a basic, literal or multi-line string.
+func (p *tomlParser) readString() (string, error) {
+	quote := p.data[p.pos]
+	triple := strings.Repeat(string(quote), 3)
+	if strings.HasPrefix(p.rest(), triple) {
+		p.advance(3)
+		// A newline right after the opening quotes is not part of the string
+		if strings.HasPrefix(p.rest(), "\n") {
+			p.advance(1)
+		} else if strings.HasPrefix(p.rest(), "\r\n") {
+			p.advance(2)
+		}
+		end := strings.Index(p.rest(), triple)
+		if end < 0 {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		// Up to two quotes may directly precede the closing delimiter
+		for end+3 < len(p.rest()) && p.rest()[end+3] == quote {
+			end++
+		}
+		raw := p.rest()[:end]
+		p.advance(end + 3)
+		if quote == '\'' {
+			return raw, nil
+		}
+		return unescapeTOML(raw, true)
+	}
+
+	p.advance(1)
+	var raw strings.Builder
+	for {
+		if p.eof() || p.data[p.pos] == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		if c == quote {
+			p.advance(1)
+			break
+		}
+		if c == '\\' && quote == '"' && p.pos+1 < len(p.data) {
+			raw.WriteString(p.data[p.pos : p.pos+2])
+			p.advance(2)
+			continue
+		}
+		raw.WriteByte(c)
+		p.advance(1)
+	}
+	if quote == '\'' {
+		return raw.String(), nil
+	}
+	return unescapeTOML(raw.String(), false)
+}
+
+// unescapeTOML resolves the escapes of a basic string. In multi-line
+// strings a backslash at the end of a line trims the following whitespace.
+func unescapeTOML(s string, multiline bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("trailing backslash in string")
+		}
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", fmt.Errorf("short \\%c escape", c)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid \\%c escape", c)
+			}
+			b.WriteRune(rune(code))
+			i += n
+		default:
+			if multiline && (c == '\n' || c == ' ' || c == '\t' || c == '\r') {
+				i = len(s) - len(strings.TrimLeft(s[i:], " \t\r\n")) - 1
+				continue
+			}
+			return "", fmt.Errorf("invalid escape \\%c", c)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,65 @@
+package library
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tldrPlaceholder matches a {{example value}} of a tldr page.
+var tldrPlaceholder = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// tldrNameInvalidChars matches what cannot appear in a placeholder name.
+var tldrNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// ParseTldr converts a tldr-style page: "- description:" lines each
+// followed by a `command` line. Tags are the page title, e.g. tar for
+// "# tar". tldr placeholders hold example values such as
+// {{path/to/file}}; they become {{path_to_file}} with the example listed
+// under **Variables:**. name is used as the tag when the page has no title.
+func ParseTldr(name, data string) []Snippet {
+	var snippets []Snippet
+	title := name
+	description := ""
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "# "):
+			title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "- "):
+			description = strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(line, "- ")), ":")
+		case len(line) >= 2 && strings.HasPrefix(line, "`") && strings.HasSuffix(line, "`"):
+			command, vars := tldrCommand(line[1 : len(line)-1])
+			snippets = append(snippets, commandSnippet(description, command, vars, []string{title}, ""))
+			description = ""
+		}
+	}
+	return snippets
+}
+
+// tldrCommand turns the example values of a tldr command into named
+// placeholders.
+func tldrCommand(command string) (string, []Variable) {
+	var vars []Variable
+	names := make(map[string]string)
+	used := make(map[string]bool)
+	command = tldrPlaceholder.ReplaceAllStringFunc(command, func(m string) string {
+		example := tldrPlaceholder.FindStringSubmatch(m)[1]
+		if name, ok := names[example]; ok {
+			return "{{" + name + "}}"
+		}
+		base := strings.Trim(tldrNameInvalidChars.ReplaceAllString(example, "_"), "_")
+		if base == "" || base[0] >= '0' && base[0] <= '9' {
+			base = "arg" + base
+		}
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[name] = true
+		names[example] = name
+		vars = append(vars, Variable{Name: name, Description: "e.g. `" + example + "`"})
+		return "{{" + name + "}}"
+	})
+	return command, vars
+}
@@ -0,0 +1,808 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"memo/cache"
+	"memo/client"
+	"memo/library"
+	"memo/query"
+	"memo/snippet"
+	"memo/sunbeam"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const memoUsage = `Usage: memo [--profile name] <command> [flags] [args]
+
+Commands:
+  post [flags] [-- command] Save the last shell command, or the given one,
+                            as a memo
+  list [flags] [query...]   Print the command memos matching a query
+  run [flags] [query...]    Pick a matching command, fill in its
+                            parameters and run it
+  edit <id>                 Open the memo in $EDITOR and save the changes
+  tag <id> [+tag|-tag]...   Add (+tag or tag) and remove (-tag) tags
+  bulk [flags] [query...]   Archive, delete, change visibility or retag every
+                            command memo matching a list query
+  export [flags] [query...] Write matching command memos to a directory of
+                            Markdown files or a JSON bundle
+  import [flags] <path>     Create memos from an exported directory, bundle
+                            or Markdown file, or from pet, navi or tldr
+                            snippets (--from), skipping duplicates
+
+<id> is a memo number such as 12 or a resource name such as memos/12.
+Command flags go before <id>; run "memo <command> -h" for them.
+
+Without arguments memo prints its Sunbeam extension manifest; install it
+with: sunbeam extension install memos /path/to/memo
+Linked as post-memo or get-memos, it behaves as memo post or memo list.
+`
+
+const profileUsage = "Memos profile from the Sunbeam config, e.g. work for the memos-work extension (default $MEMO_PROFILE or memos)"
+
+// extensionPreferences are the preferences Sunbeam passed when memo runs
+// as an extension, nil otherwise.
+var extensionPreferences *sunbeam.Preferences
+
+// stateDir returns the directory holding the state kept between runs, such
+// as spooled memos and remembered parameters.
+func stateDir() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+	return filepath.Join(stateHome, "memo")
+}
+
+// newClient returns a client for the memos server of profile, or of the
+// Sunbeam extension preferences when running as an extension.
+func newClient(profile string) (*client.Client, sunbeam.Preferences, error) {
+	var preferences sunbeam.Preferences
+	if extensionPreferences != nil {
+		preferences = *extensionPreferences
+	} else {
+		// Retrieve memo preferences, falling back to environment variables
+		p, err := sunbeam.LoadPreferences(profile)
+		if err != nil {
+			return nil, sunbeam.Preferences{}, err
+		}
+		preferences = p
+	}
+	// The token may reference a keyring, command or file; it is only read
+	// once a request is made
+	c := client.New(preferences.MemoURL, "")
+	c.TokenSource = preferences.ResolveToken
+	return c, preferences, nil
+}
+
+// manifest describes memo as a Sunbeam extension.
+var manifest = sunbeam.Manifest{
+	Title:       "Memos",
+	Description: "Search, run and save shell commands kept in Memos",
+	Preferences: sunbeam.PreferenceInputs,
+	Commands: []sunbeam.Command{
+		{Name: "list", Title: "Search Command Memos", Mode: sunbeam.ModeFilter, Params: []sunbeam.Input{
+			{Name: "tags", Title: "Tags (comma-separated)", Type: sunbeam.InputString, Optional: true},
+			{Name: "query", Title: "Query", Type: sunbeam.InputString, Optional: true},
+		}},
+		{Name: "post", Title: "Save Last Command", Mode: sunbeam.ModeTTY, Params: []sunbeam.Input{
+			{Name: "tags", Title: "Tags (comma-separated)", Type: sunbeam.InputString, Optional: true},
+			{Name: "run", Title: "Run it and save the output", Type: sunbeam.InputBoolean, Optional: true},
+		}},
+		{Name: "flush", Title: "Post Saved Memos", Mode: sunbeam.ModeSilent},
+		// A tty command, so that the deletion can be confirmed
+		{Name: "delete", Title: "Delete Memo", Mode: sunbeam.ModeTTY, Hidden: true, Params: []sunbeam.Input{
+			{Name: "name", Title: "Memo name, e.g. memos/12", Type: sunbeam.InputString},
+		}},
+	},
+}
+
+// runExtension handles a Sunbeam command invocation. Sunbeam passes the
+// preferences, so the Sunbeam config is not read.
+func runExtension(arg string) error {
+	payload, err := sunbeam.ParsePayload(arg)
+	if err != nil {
+		return err
+	}
+	preferences, err := payload.MemoPreferences()
+	if err != nil {
+		return err
+	}
+	command, args, err := extensionArgs(payload)
+	if err != nil {
+		return err
+	}
+	extensionPreferences = &preferences
+
+	switch command {
+	case "list":
+		listMemos(args, "", false)
+	case "post":
+		postMemo(args, "")
+	}
+	return nil
+}
+
+// extensionArgs returns the memo command, list or post, and the arguments
+// an extension command runs it with.
+func extensionArgs(payload sunbeam.Payload) (string, []string, error) {
+	tags, err := payload.String("tags")
+	if err != nil {
+		return "", nil, err
+	}
+	switch payload.Command {
+	case "list":
+		q, err := payload.String("query")
+		if err != nil {
+			return "", nil, err
+		}
+		return "list", []string{"--format", "sunbeam", "--tags", tags, "--query", q}, nil
+	case "post":
+		run, err := payload.Bool("run")
+		if err != nil {
+			return "", nil, err
+		}
+		return "post", []string{"--tags", tags, "--run=" + strconv.FormatBool(run)}, nil
+	case "flush":
+		return "post", []string{"--flush"}, nil
+	case "delete":
+		name, err := payload.String("name")
+		if err != nil {
+			return "", nil, err
+		}
+		if name == "" {
+			return "", nil, fmt.Errorf("delete needs the name of a memo")
+		}
+		return "list", []string{"--delete", name}, nil
+	}
+	return "", nil, fmt.Errorf("unknown extension command %q", payload.Command)
+}
+
+// memoName returns the resource name for a memo id given on the command line.
+func memoName(id string) string {
+	if strings.HasPrefix(id, "memos/") {
+		return id
+	}
+	return "memos/" + id
+}
+
+// conflictHint explains how to resolve a conflicting update.
+func conflictHint(err error) error {
+	var conflict *client.ConflictError
+	if errors.As(err, &conflict) {
+		return fmt.Errorf("%w; run the command again to start from the new version, or pass --force to overwrite it", err)
+	}
+	return err
+}
+
+// editContent opens content in the user's editor and returns the result.
+// The editor comes from $VISUAL or $EDITOR and may include arguments.
+func editContent(name, content string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", strings.ReplaceAll(name, "/", "-")+"-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// Let the shell split the editor command, the file is passed as $1
+	cmd := exec.Command("/bin/sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited memo: %w", err)
+	}
+	return string(edited), nil
+}
+
+// saveRejectedEdit keeps edited content that could not be saved so the work
+// is not lost, returning the file it was written to.
+func saveRejectedEdit(name, content string) (string, error) {
+	f, err := os.CreateTemp("", strings.ReplaceAll(name, "/", "-")+"-edit-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.WriteString(content)
+	return f.Name(), err
+}
+
+// applyTagChanges returns tags with the changes applied: "+tag" or "tag"
+// adds a tag, "-tag" removes it. The order of existing tags is kept and new
+// tags are appended.
+func applyTagChanges(tags []string, changes []string) ([]string, error) {
+	result := slices.Clone(tags)
+	for _, change := range changes {
+		remove := strings.HasPrefix(change, "-")
+		tag := snippet.NormalizeTag(strings.TrimPrefix(strings.TrimPrefix(change, "-"), "+"))
+		if tag == "" {
+			return nil, fmt.Errorf("invalid tag %q", change)
+		}
+		if remove {
+			result = slices.DeleteFunc(result, func(t string) bool { return t == tag })
+		} else if !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// editMemo implements "memo edit".
+func editMemo(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	force := fs.Bool("force", false, "Save even if the memo changed on the server while editing")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: memo edit [--force] <id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	memo, err := c.Get(ctx, memoName(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+	since := memo.UpdateTime
+
+	content, err := editContent(memo.Name, memo.Content)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(content) == "" {
+		return errors.New("memo content is empty, nothing saved")
+	}
+	if content == memo.Content {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	memo.Content = content
+	if *force {
+		_, err = c.Update(ctx, memo, "content")
+	} else {
+		_, err = c.UpdateIfUnchanged(ctx, memo, since, "content")
+	}
+	if err != nil {
+		if path, saveErr := saveRejectedEdit(memo.Name, content); saveErr == nil {
+			log.Printf("Your edit was saved to %s", path)
+		}
+		return conflictHint(err)
+	}
+	fmt.Printf("Updated %s\n", memo.Name)
+	return nil
+}
+
+// tagMemo implements "memo tag".
+func tagMemo(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("tag", flag.ExitOnError)
+	force := fs.Bool("force", false, "Save even if the memo changed on the server in the meantime")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: memo tag [--force] <id> [+tag|-tag]...")
+		fs.PrintDefaults()
+	}
+	// Flags stop at the id, so -tag arguments after it are left alone
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	memo, err := c.Get(ctx, memoName(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+	since := memo.UpdateTime
+
+	tags := snippet.ExtractTags(memo.Content)
+	if fs.NArg() == 1 {
+		fmt.Println(strings.Join(tags, " "))
+		return nil
+	}
+	newTags, err := applyTagChanges(tags, fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if slices.Equal(tags, newTags) {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	memo.Content = snippet.ReplaceTags(memo.Content, newTags)
+	if *force {
+		_, err = c.Update(ctx, memo, "content")
+	} else {
+		_, err = c.UpdateIfUnchanged(ctx, memo, since, "content")
+	}
+	if err != nil {
+		return conflictHint(err)
+	}
+	fmt.Printf("Tags of %s: %s\n", memo.Name, strings.Join(newTags, " "))
+	return nil
+}
+
+// bulkChange is one memo a bulk operation will act on.
+type bulkChange struct {
+	memo client.Memo
+	// tags are the new tags when the operation changes tags
+	tags []string
+}
+
+// bulkFailure is a memo a bulk operation could not change.
+type bulkFailure struct {
+	name string
+	err  error
+}
+
+// runBulk applies op to every change with at most jobs requests in flight
+// and returns the number of successes and the failures in input order.
+func runBulk(ctx context.Context, changes []bulkChange, jobs int, op func(context.Context, bulkChange) error) (int, []bulkFailure) {
+	errs := make([]error, len(changes))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range max(jobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				errs[i] = op(ctx, changes[i])
+			}
+		}()
+	}
+	for i := range changes {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	var failures []bulkFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, bulkFailure{changes[i].memo.Name, err})
+		}
+	}
+	return len(changes) - len(failures), failures
+}
+
+// matchesQuery reports whether any command of memo matches q.
+func matchesQuery(memo *client.Memo, q *query.Query) bool {
+	tags := memo.TagList()
+	for _, block := range snippet.CommandBlocks(memo.Content, "") {
+		if _, ok := q.Match(query.Doc{Command: block.Code, Tags: tags, Lang: block.Lang}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// printBulkChanges lists the memos a bulk operation acts on.
+func printBulkChanges(changes []bulkChange) {
+	for _, ch := range changes {
+		line, _, _ := strings.Cut(snippet.ExtractCodeBlock(ch.memo.Content), "\n")
+		fmt.Printf("%-10s %-9s %s  %s", ch.memo.Name, strings.ToLower(string(ch.memo.Visibility)),
+			ch.memo.UpdateTime.Local().Format("2006-01-02"), line)
+		if ch.tags != nil {
+			fmt.Printf("  -> #%s", strings.Join(ch.tags, " #"))
+		}
+		fmt.Println()
+	}
+}
+
+// bulkMemos implements "memo bulk".
+func bulkMemos(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("bulk", flag.ExitOnError)
+	tags := fs.String("tags", "", "Comma-separated tags every memo must carry (server-side filter)")
+	queryFlag := fs.String("query", "", `get-memos search, e.g. 'tag:cmd AND tag:old'; arguments are appended`)
+	archive := fs.Bool("archive", false, "Archive the matching memos")
+	deleteMemos := fs.Bool("delete", false, "Delete the matching memos permanently")
+	visibilityFlag := fs.String("visibility", "", "Set the visibility of the matching memos: private, protected or public")
+	addTags := fs.String("add-tag", "", "Comma-separated tags to add to the matching memos")
+	removeTags := fs.String("remove-tag", "", "Comma-separated tags to remove from the matching memos")
+	dryRun := fs.Bool("dry-run", false, "List the memos that would change and exit")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	jobs := fs.Int("jobs", 4, "Number of memos changed concurrently")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: memo bulk [flags] [query...]\n\nExactly one of --archive, --delete, --visibility or --add-tag/--remove-tag is required.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// Work out the action
+	var tagChanges []string
+	for _, tag := range strings.Split(*addTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tagChanges = append(tagChanges, "+"+tag)
+		}
+	}
+	for _, tag := range strings.Split(*removeTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tagChanges = append(tagChanges, "-"+tag)
+		}
+	}
+	actions := 0
+	for _, set := range []bool{*archive, *deleteMemos, *visibilityFlag != "", len(tagChanges) > 0} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var visibility client.Visibility
+	if *visibilityFlag != "" {
+		v, err := parseVisibility(*visibilityFlag)
+		if err != nil {
+			return err
+		}
+		visibility = v
+	}
+	if _, err := applyTagChanges(nil, tagChanges); err != nil {
+		return err
+	}
+
+	// Select the memos
+	queryText := strings.TrimSpace(*queryFlag + " " + query.JoinArgs(fs.Args()))
+	filter := client.TagFilter(strings.Split(*tags, ","))
+	if queryText == "" && filter == "" {
+		return errors.New("refusing to act on every memo, give a query or --tags")
+	}
+	q, err := query.Parse(queryText)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	memos, err := c.ListAll(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var changes []bulkChange
+	for i := range memos {
+		memo := memos[i]
+		if !matchesQuery(&memo, q) {
+			continue
+		}
+		ch := bulkChange{memo: memo}
+		switch {
+		case visibility != "" && memo.Visibility == visibility:
+			continue
+		case len(tagChanges) > 0:
+			old := snippet.ExtractTags(memo.Content)
+			ch.tags, _ = applyTagChanges(old, tagChanges)
+			if slices.Equal(old, ch.tags) {
+				continue
+			}
+		}
+		changes = append(changes, ch)
+	}
+
+	verb, done := "change", "Changed"
+	switch {
+	case *archive:
+		verb, done = "archive", "Archived"
+	case *deleteMemos:
+		verb, done = "delete", "Deleted"
+	case visibility != "":
+		verb, done = "make "+strings.ToLower(string(visibility)), "Updated"
+	case len(tagChanges) > 0:
+		verb, done = "retag", "Retagged"
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No memos to " + verb)
+		return nil
+	}
+	printBulkChanges(changes)
+	if *dryRun {
+		fmt.Printf("Would %s %d memos\n", verb, len(changes))
+		return nil
+	}
+	if !*yes {
+		question := fmt.Sprintf("%s %d memos?", strings.ToUpper(verb[:1])+verb[1:], len(changes))
+		if !confirmNo(bufio.NewReader(os.Stdin), os.Stdout, question) {
+			return errors.New("cancelled")
+		}
+	}
+
+	op := func(ctx context.Context, ch bulkChange) error {
+		memo := ch.memo
+		switch {
+		case *archive:
+			_, err := c.Archive(ctx, memo.Name)
+			return err
+		case *deleteMemos:
+			return c.Delete(ctx, memo.Name)
+		case visibility != "":
+			memo.Visibility = visibility
+			_, err := c.UpdateIfUnchanged(ctx, &memo, ch.memo.UpdateTime, "visibility")
+			return err
+		default:
+			memo.Content = snippet.ReplaceTags(memo.Content, ch.tags)
+			_, err := c.UpdateIfUnchanged(ctx, &memo, ch.memo.UpdateTime, "content")
+			return err
+		}
+	}
+	succeeded, failures := runBulk(ctx, changes, *jobs, op)
+
+	// Archived and deleted memos no longer belong in the local cache
+	if *archive || *deleteMemos {
+		failed := make(map[string]bool)
+		for _, f := range failures {
+			failed[f.name] = true
+		}
+		for _, ch := range changes {
+			if failed[ch.memo.Name] {
+				continue
+			}
+			if err := cache.Forget(ch.memo.Name); err != nil {
+				log.Printf("Warning: could not update cache: %v", err)
+				break
+			}
+		}
+	}
+
+	fmt.Printf("%s %d of %d memos\n", done, succeeded, len(changes))
+	if len(failures) > 0 {
+		fmt.Printf("%d failed:\n", len(failures))
+		for _, f := range failures {
+			fmt.Printf("  %s: %v\n", f.name, f.err)
+		}
+		return fmt.Errorf("%d of %d memos failed", len(failures), len(changes))
+	}
+	return nil
+}
+
+// exportMemos implements "memo export".
+func exportMemos(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	tags := fs.String("tags", "", "Comma-separated tags every memo must carry (server-side filter)")
+	queryFlag := fs.String("query", "", `get-memos search, e.g. 'tag:k8s'; arguments are appended`)
+	format := fs.String("format", "md", "Export format: md (a directory of Markdown files) or json (a single bundle)")
+	out := fs.String("out", "", `Output directory for md (default "memo-library"), output file for json (default "-", stdout)`)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: memo export [flags] [query...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *format != "md" && *format != "json" {
+		return fmt.Errorf("unknown format %q, use md or json", *format)
+	}
+
+	q, err := query.Parse(strings.TrimSpace(*queryFlag + " " + query.JoinArgs(fs.Args())))
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	memos, err := c.ListAll(ctx, client.TagFilter(strings.Split(*tags, ",")))
+	if err != nil {
+		return err
+	}
+	var snippets []library.Snippet
+	for i := range memos {
+		if matchesQuery(&memos[i], q) {
+			snippets = append(snippets, library.FromMemo(&memos[i]))
+		}
+	}
+
+	if *format == "json" {
+		data, err := library.MarshalBundle(c.BaseURL, snippets)
+		if err != nil {
+			return err
+		}
+		if *out == "" || *out == "-" {
+			_, err = os.Stdout.Write(append(data, '\n'))
+			return err
+		}
+		if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d memos to %s\n", len(snippets), *out)
+		return nil
+	}
+
+	dir := *out
+	if dir == "" {
+		dir = "memo-library"
+	}
+	if err := library.WriteDir(dir, snippets); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d memos to %s\n", len(snippets), dir)
+	return nil
+}
+
+// importMemos implements "memo import". Memos get a new creation time; the
+// one recorded in the library is only kept in the files.
+func importMemos(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without creating memos")
+	visibilityFlag := fs.String("visibility", "private", "Visibility of snippets that do not set one: private, protected or public")
+	from := fs.String("from", "library", "Source format: library (memo export output), pet (snippet.toml), navi (.cheat files) or tldr (pages)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: memo import [flags] <file|directory>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	snippets, err := library.ReadFormat(*from, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	existing, err := c.ListAll(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	var created, skipped, conflicts int
+	var failures []bulkFailure
+	for i, item := range library.PlanImport(snippets, existing) {
+		label := item.Snippet.FileName(i)
+		switch item.Action {
+		case library.ActionSkip:
+			skipped++
+			fmt.Printf("skip      %s: already exists as %s\n", label, item.Existing)
+			continue
+		case library.ActionConflict:
+			conflicts++
+			fmt.Printf("conflict  %s: %s has the same command with different content or tags\n", label, item.Existing)
+			continue
+		}
+
+		visibilityName := item.Snippet.Visibility
+		if visibilityName == "" {
+			visibilityName = *visibilityFlag
+		}
+		visibility, err := parseVisibility(visibilityName)
+		if err != nil {
+			failures = append(failures, bulkFailure{label, err})
+			continue
+		}
+
+		if *dryRun {
+			created++
+			fmt.Printf("create    %s (%s)\n", label, strings.ToLower(string(visibility)))
+			continue
+		}
+		memo, err := c.Create(ctx, client.CreateMemoRequest{Content: item.Snippet.MemoContent(), Visibility: visibility})
+		if err != nil {
+			failures = append(failures, bulkFailure{label, err})
+			continue
+		}
+		created++
+		fmt.Printf("create    %s -> %s\n", label, memo.Name)
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d memos, skipped %d duplicates, %d conflicts\n", verb, created, skipped, conflicts)
+	if len(failures) > 0 {
+		fmt.Printf("%d failed:\n", len(failures))
+		for _, f := range failures {
+			fmt.Printf("  %s: %v\n", f.name, f.err)
+		}
+		return fmt.Errorf("%d of %d snippets failed", len(failures), len(snippets))
+	}
+	return nil
+}
+
+func main() {
+	// post-memo and get-memos links keep the old command lines working,
+	// including get-memos run
+	switch filepath.Base(os.Args[0]) {
+	case "post-memo":
+		postMemo(os.Args[1:], "")
+		return
+	case "get-memos":
+		if len(os.Args) > 1 && os.Args[1] == "run" {
+			listMemos(os.Args[2:], "", true)
+			return
+		}
+		listMemos(os.Args[1:], "", false)
+		return
+	}
+
+	// Sunbeam runs extensions without arguments to read the manifest, then
+	// with a JSON payload for each command
+	if len(os.Args) == 1 {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			log.Fatalf("Error converting to JSON: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	if strings.HasPrefix(os.Args[1], "{") {
+		if err := runExtension(os.Args[1]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	profile := flag.String("profile", "", profileUsage)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), memoUsage+"\nGlobal flags, given before <command>:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 || flag.Arg(0) == "help" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "post":
+		postMemo(args, *profile)
+		return
+	case "list":
+		listMemos(args, *profile, false)
+		return
+	case "run":
+		listMemos(args, *profile, true)
+		return
+	case "edit", "tag", "bulk", "export", "import":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, _, err := newClient(*profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "edit":
+		err = editMemo(ctx, c, args)
+	case "tag":
+		err = tagMemo(ctx, c, args)
+	case "bulk":
+		err = bulkMemos(ctx, c, args)
+	case "export":
+		err = exportMemos(ctx, c, args)
+	case "import":
+		err = importMemos(ctx, c, args)
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"memo/client"
+	"memo/memotest"
+	"memo/snippet"
+	"memo/sunbeam"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestServer starts a fake Memos server and a client for it.
+func newTestServer(t *testing.T) (*memotest.Server, *client.Client) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	srv := memotest.NewServer("token")
+	t.Cleanup(srv.Close)
+	return srv, client.New(srv.URL, "token")
+}
+
+func TestApplyTagChanges(t *testing.T) {
+	tests := []struct {
+		tags    []string
+		changes []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"cmd"}, []string{"k8s"}, []string{"cmd", "k8s"}, false},
+		{[]string{"cmd"}, []string{"+k8s", "+#prod"}, []string{"cmd", "k8s", "prod"}, false},
+		{[]string{"cmd", "old", "k8s"}, []string{"-old"}, []string{"cmd", "k8s"}, false},
+		{[]string{"cmd", "k8s"}, []string{"+K8s"}, []string{"cmd", "k8s"}, false},
+		{[]string{"cmd"}, []string{"+My Tag"}, []string{"cmd", "my-tag"}, false},
+		{[]string{"cmd", "k8s"}, []string{"-k8s", "+k8s"}, []string{"cmd", "k8s"}, false},
+		{[]string{"cmd"}, []string{"-missing"}, []string{"cmd"}, false},
+		{nil, []string{"+"}, nil, true},
+		{nil, []string{"-#"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := applyTagChanges(tt.tags, tt.changes)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("applyTagChanges(%q, %q) error = %v, want error %v", tt.tags, tt.changes, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("applyTagChanges(%q, %q) = %q, want %q", tt.tags, tt.changes, got, tt.want)
+		}
+	}
+}
+
+func TestTagMemo(t *testing.T) {
+	srv, c := newTestServer(t)
+	ctx := context.Background()
+	memo := srv.AddMemo("```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd #old", "")
+
+	if err := tagMemo(ctx, c, []string{memo.Name, "+k8s", "-old"}); err != nil {
+		t.Fatalf("tagMemo: %v", err)
+	}
+	got, err := c.Get(ctx, memo.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := snippet.ExtractTags(got.Content); !slices.Equal(tags, []string{"cmd", "k8s"}) {
+		t.Errorf("tags after tagMemo = %q, want [cmd k8s]", tags)
+	}
+	if code := snippet.ExtractCodeBlock(got.Content); code != "kubectl get pods" {
+		t.Errorf("command after tagMemo = %q", code)
+	}
+}
+
+func TestTagMemoConflict(t *testing.T) {
+	srv, c := newTestServer(t)
+	ctx := context.Background()
+	memo := srv.AddMemo("```shell\nls\n```\n\n**Tags:**\n#cmd", "")
+
+	// The memo is edited elsewhere after tagMemo has read it
+	gets := 0
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/v1/"+memo.Name {
+			if gets++; gets == 2 {
+				other := memo
+				other.Content = "```shell\nls -la\n```\n\n**Tags:**\n#cmd"
+				if _, err := client.New(srv.URL, "token").Update(ctx, &other, "content"); err != nil {
+					t.Errorf("concurrent Update: %v", err)
+				}
+			}
+		}
+		return false
+	}
+
+	err := tagMemo(ctx, c, []string{memo.Name, "+new"})
+	if !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("tagMemo error = %v, want a conflict", err)
+	}
+	got, err := c.Get(ctx, memo.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := snippet.ExtractCodeBlock(got.Content); code != "ls -la" {
+		t.Errorf("concurrent edit overwritten, command is %q", code)
+	}
+
+	// Running the command again starts from the new version
+	srv.Intercept = nil
+	if err := tagMemo(ctx, c, []string{memo.Name, "+new"}); err != nil {
+		t.Fatalf("tagMemo again: %v", err)
+	}
+	got, err = c.Get(ctx, memo.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := snippet.ExtractTags(got.Content); !slices.Equal(tags, []string{"cmd", "new"}) {
+		t.Errorf("tags after retry = %q, want [cmd new]", tags)
+	}
+	if code := snippet.ExtractCodeBlock(got.Content); code != "ls -la" {
+		t.Errorf("command after retry = %q, want ls -la", code)
+	}
+}
+
+func TestRunBulk(t *testing.T) {
+	srv, c := newTestServer(t)
+	ctx := context.Background()
+	var changes []bulkChange
+	for i := range 10 {
+		memo := srv.AddMemo(fmt.Sprintf("```shell\necho %d\n```\n\n**Tags:**\n#cmd", i), "")
+		changes = append(changes, bulkChange{memo: memo})
+	}
+	// Two deletes fail on the server
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodDelete && (strings.HasSuffix(r.URL.Path, "/memos/3") || strings.HasSuffix(r.URL.Path, "/memos/8")) {
+			http.Error(w, `{"code": 13, "message": "database is locked"}`, http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	succeeded, failures := runBulk(ctx, changes, 3, func(ctx context.Context, ch bulkChange) error {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		return c.Delete(ctx, ch.memo.Name)
+	})
+
+	if succeeded != 8 {
+		t.Errorf("succeeded = %d, want 8", succeeded)
+	}
+	var names []string
+	for _, f := range failures {
+		names = append(names, f.name)
+		if f.err == nil || !strings.Contains(f.err.Error(), "database is locked") {
+			t.Errorf("failure %s has error %v", f.name, f.err)
+		}
+	}
+	if !slices.Equal(names, []string{"memos/3", "memos/8"}) {
+		t.Errorf("failures = %q, want memos/3 and memos/8 in input order", names)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("%d operations ran at once, want at most 3", maxInFlight)
+	}
+	if left := srv.Memos(); len(left) != 2 {
+		t.Errorf("%d memos left on the server, want the 2 that failed", len(left))
+	}
+}
+
+func TestBulkMemosPartialFailure(t *testing.T) {
+	srv, c := newTestServer(t)
+	ctx := context.Background()
+	for _, content := range []string{
+		"```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd #k8s",
+		"```shell\nkubectl logs api\n```\n\n**Tags:**\n#cmd #k8s",
+		"```shell\nls\n```\n\n**Tags:**\n#cmd",
+	} {
+		srv.AddMemo(content, "")
+	}
+	srv.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/memos/2") {
+			http.Error(w, `{"code": 13, "message": "internal error"}`, http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+
+	err := bulkMemos(ctx, c, []string{"--tags", "k8s", "--add-tag", "kubectl", "--remove-tag", "k8s", "--yes", "--jobs", "2"})
+	if err == nil || err.Error() != "1 of 2 memos failed" {
+		t.Fatalf("bulkMemos error = %v, want 1 of 2 memos failed", err)
+	}
+
+	want := map[string][]string{
+		"memos/1": {"cmd", "kubectl"},
+		"memos/2": {"cmd", "k8s"},
+		"memos/3": {"cmd"},
+	}
+	for _, memo := range srv.Memos() {
+		if tags := snippet.ExtractTags(memo.Content); !slices.Equal(tags, want[memo.Name]) {
+			t.Errorf("tags of %s = %q, want %q", memo.Name, tags, want[memo.Name])
+		}
+	}
+}
+
+func TestExtensionArgs(t *testing.T) {
+	tests := []struct {
+		command  string
+		params   map[string]any
+		want     string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{"list", map[string]any{"tags": " k8s,prod ", "query": "rollout"}, "list",
+			[]string{"--format", "sunbeam", "--tags", "k8s,prod", "--query", "rollout"}, false},
+		{"list", nil, "list", []string{"--format", "sunbeam", "--tags", "", "--query", ""}, false},
+		{"post", map[string]any{"tags": "k8s", "run": true}, "post", []string{"--tags", "k8s", "--run=true"}, false},
+		{"post", nil, "post", []string{"--tags", "", "--run=false"}, false},
+		{"post", map[string]any{"run": "yes"}, "", nil, true},
+		{"flush", nil, "post", []string{"--flush"}, false},
+		{"delete", map[string]any{"name": "memos/12"}, "list", []string{"--delete", "memos/12"}, false},
+		{"delete", map[string]any{"name": " "}, "", nil, true},
+		{"delete", nil, "", nil, true},
+		{"list", map[string]any{"tags": 5}, "", nil, true},
+		{"export", nil, "", nil, true},
+	}
+	for _, tt := range tests {
+		command, args, err := extensionArgs(sunbeam.Payload{Command: tt.command, Params: tt.params})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("extensionArgs(%s, %v) error = %v, want error %v", tt.command, tt.params, err, tt.wantErr)
+			continue
+		}
+		if command != tt.want || !slices.Equal(args, tt.wantArgs) {
+			t.Errorf("extensionArgs(%s, %v) = %s %q, want %s %q", tt.command, tt.params, command, args, tt.want, tt.wantArgs)
+		}
+	}
+}
+
+func TestManifestJSON(t *testing.T) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Preferences []sunbeam.Input
+		Commands    []sunbeam.Command
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.Preferences, sunbeam.PreferenceInputs) {
+		t.Errorf("manifest preferences = %+v, want %+v", got.Preferences, sunbeam.PreferenceInputs)
+	}
+	var names []string
+	for _, command := range got.Commands {
+		names = append(names, command.Name)
+		// Every command is handled by runExtension
+		if _, _, err := extensionArgs(sunbeam.Payload{Command: command.Name, Params: map[string]any{"name": "memos/1"}}); err != nil {
+			t.Errorf("manifest command %s: %v", command.Name, err)
+		}
+	}
+	if !slices.Equal(names, []string{"list", "post", "flush", "delete"}) {
+		t.Errorf("manifest commands = %q", names)
+	}
+}
+
+func TestRunExtensionList(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.AddMemo("```shell\nkubectl rollout restart deploy/api\n```\n\n**Tags:**\n#cmd #k8s", "")
+	srv.AddMemo("```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd #k8s", "")
+	srv.AddMemo("```shell\nls -la\n```\n\n**Tags:**\n#cmd", "")
+	t.Cleanup(func() { extensionPreferences = nil })
+
+	payload, err := json.Marshal(map[string]any{
+		"command":     "list",
+		"preferences": map[string]any{"memo_url": srv.URL, "memo_token": "token"},
+		"params":      map[string]any{"tags": "k8s", "query": "rollout"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() {
+		if err := runExtension(string(payload)); err != nil {
+			t.Errorf("runExtension: %v", err)
+		}
+	})
+
+	var page sunbeam.List
+	if err := json.Unmarshal([]byte(out), &page); err != nil {
+		t.Fatalf("output is not a list page: %v\n%s", err, out)
+	}
+	if page.Type != "list" || len(page.Items) != 1 || page.Items[0].Title != "kubectl rollout restart deploy/api" {
+		t.Fatalf("list page = %+v, want the rollout command", page)
+	}
+	// Running as an extension the delete action goes through the extension
+	actions := page.Items[0].Actions
+	if del := actions[len(actions)-1]; del.Type != sunbeam.ActionRun || del.Command != "delete" || del.Params["name"] != "memos/1" {
+		t.Errorf("delete action = %+v, want the delete command for memos/1", del)
+	}
+	for _, request := range srv.Requests() {
+		if !strings.Contains(request, "tag_search") {
+			t.Errorf("request %s does not filter by tag", request)
+		}
+	}
+}
+
+// captureStdout returns what f prints on stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
@@ -0,0 +1,325 @@
+// Package memotest provides an in-process fake of the Memos v1 API for tests.
+package memotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"memo/client"
+	"memo/snippet"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is used for list requests that do not set pageSize.
+const DefaultPageSize = 10
+
+// tagSearchRe extracts the quoted tags of a tag_search filter.
+var tagSearchRe = regexp.MustCompile(`'([^']*)'`)
+
+// Server is a fake Memos server implementing the memo endpoints used by the
+// memo tools: list with pagination, tag_search filters and update time
+// order, get, create, patch, delete and tag listing.
+type Server struct {
+	*httptest.Server
+
+	// Token is the bearer token every request must carry.
+	Token string
+	// Intercept, when set, runs before the fake handlers. Returning true
+	// means it has written the response itself, e.g. to serve broken JSON.
+	Intercept func(w http.ResponseWriter, r *http.Request) bool
+
+	mu       sync.Mutex
+	memos    []client.Memo
+	nextID   int
+	requests []string
+	// now is the last time handed out by tickLocked
+	now time.Time
+}
+
+// NewServer starts a fake server accepting token. Callers must Close it.
+func NewServer(token string) *Server {
+	s := &Server{Token: token, nextID: 1}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/memos", s.listMemos)
+	mux.HandleFunc("POST /api/v1/memos", s.createMemo)
+	mux.HandleFunc("GET /api/v1/memos/-/tags", s.listTags)
+	mux.HandleFunc("GET /api/v1/memos/{id}", s.getMemo)
+	mux.HandleFunc("PATCH /api/v1/memos/{id}", s.updateMemo)
+	mux.HandleFunc("DELETE /api/v1/memos/{id}", s.deleteMemo)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.mu.Unlock()
+
+		if s.Intercept != nil && s.Intercept(w, r) {
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+s.Token {
+			writeError(w, http.StatusUnauthorized, 16, "failed to authenticate user")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// AddMemo stores a memo as if it had been created through the API.
+func (s *Server) AddMemo(content string, visibility client.Visibility) client.Memo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addLocked(content, visibility)
+}
+
+// Memos returns a copy of the stored memos, oldest first.
+func (s *Server) Memos() []client.Memo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.memos)
+}
+
+// Requests returns the method and request URI of every request received.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) addLocked(content string, visibility client.Visibility) client.Memo {
+	if visibility == "" {
+		visibility = client.VisibilityPrivate
+	}
+	now := s.tickLocked()
+	id := s.nextID
+	s.nextID++
+
+	memo := client.Memo{
+		Name:        fmt.Sprintf("memos/%d", id),
+		UID:         fmt.Sprintf("uid%d", id),
+		Creator:     "users/1",
+		CreateTime:  now,
+		UpdateTime:  now,
+		DisplayTime: now,
+		Content:     content,
+		Visibility:  visibility,
+		RowStatus:   "ACTIVE",
+		Property:    &client.MemoProperty{Tags: snippet.ExtractTags(content)},
+	}
+	s.memos = append(s.memos, memo)
+	return memo
+}
+
+// tickLocked returns the current time in whole seconds, as the server
+// stores it, moved past the previous call so that every change gets a
+// distinct time.
+func (s *Server) tickLocked() time.Time {
+	now := time.Now().UTC().Truncate(time.Second)
+	if !now.After(s.now) {
+		now = s.now.Add(time.Second)
+	}
+	s.now = now
+	return now
+}
+
+// indexLocked returns the position of the memo named memos/{id}.
+func (s *Server) indexLocked(id string) int {
+	return slices.IndexFunc(s.memos, func(m client.Memo) bool {
+		return m.Name == "memos/"+id
+	})
+}
+
+func (s *Server) listMemos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pageSize := DefaultPageSize
+	if v := query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, 3, "invalid page size")
+			return
+		}
+		if n > 0 {
+			pageSize = n
+		}
+	}
+	offset := 0
+	if v := query.Get("pageToken"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, 3, "invalid page token")
+			return
+		}
+		offset = n
+	}
+
+	// tag_search == ['a','b'] matches memos carrying every listed tag
+	var wantTags []string
+	if filter := query.Get("filter"); strings.Contains(filter, "tag_search") {
+		for _, m := range tagSearchRe.FindAllStringSubmatch(filter, -1) {
+			if m[1] != "" {
+				wantTags = append(wantTags, m[1])
+			}
+		}
+	}
+
+	s.mu.Lock()
+	var matching []client.Memo
+	for _, memo := range s.memos {
+		if memo.RowStatus != "ARCHIVED" && hasAllTags(memo.ServerTags(), wantTags) {
+			matching = append(matching, memo)
+		}
+	}
+	s.mu.Unlock()
+
+	// Newest first, as the real server lists them, or by update time when
+	// asked
+	slices.Reverse(matching)
+	switch query.Get("orderBy") {
+	case client.OrderByUpdateTime:
+		slices.SortStableFunc(matching, func(a, b client.Memo) int {
+			return b.UpdateTime.Compare(a.UpdateTime)
+		})
+	case client.OrderByUpdateTimeAsc:
+		slices.SortStableFunc(matching, func(a, b client.Memo) int {
+			return a.UpdateTime.Compare(b.UpdateTime)
+		})
+	}
+
+	resp := client.ListMemosResponse{Memos: []client.Memo{}}
+	if offset < len(matching) {
+		end := min(offset+pageSize, len(matching))
+		resp.Memos = matching[offset:end]
+		if end < len(matching) {
+			resp.NextPageToken = strconv.Itoa(end)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) createMemo(w http.ResponseWriter, r *http.Request) {
+	var req client.CreateMemoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, 3, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, 3, "content is required")
+		return
+	}
+
+	s.mu.Lock()
+	memo := s.addLocked(req.Content, req.Visibility)
+	s.mu.Unlock()
+	writeJSON(w, memo)
+}
+
+func (s *Server) getMemo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, 5, "memo not found")
+		return
+	}
+	writeJSON(w, s.memos[i])
+}
+
+func (s *Server) updateMemo(w http.ResponseWriter, r *http.Request) {
+	var patch client.Memo
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, http.StatusBadRequest, 3, "invalid request body")
+		return
+	}
+	mask := r.URL.Query().Get("updateMask")
+	if mask == "" {
+		writeError(w, http.StatusBadRequest, 3, "update mask is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, 5, "memo not found")
+		return
+	}
+	memo := s.memos[i]
+	for _, field := range strings.Split(mask, ",") {
+		switch strings.TrimSpace(field) {
+		case "content":
+			memo.Content = patch.Content
+			memo.Property = &client.MemoProperty{Tags: snippet.ExtractTags(patch.Content)}
+		case "visibility":
+			memo.Visibility = patch.Visibility
+		case "pinned":
+			memo.Pinned = patch.Pinned
+		case "row_status", "rowStatus":
+			memo.RowStatus = patch.RowStatus
+		default:
+			writeError(w, http.StatusBadRequest, 3, "unsupported update mask path: "+field)
+			return
+		}
+	}
+	memo.UpdateTime = s.tickLocked()
+	s.memos[i] = memo
+	writeJSON(w, memo)
+}
+
+func (s *Server) deleteMemo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, 5, "memo not found")
+		return
+	}
+	s.memos = slices.Delete(s.memos, i, i+1)
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	amounts := make(map[string]int)
+	for _, memo := range s.memos {
+		for _, tag := range memo.ServerTags() {
+			amounts[tag]++
+		}
+	}
+	writeJSON(w, map[string]any{"tagAmounts": amounts})
+}
+
+func hasAllTags(tags, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the gRPC gateway format.
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"code":    code,
+		"message": message,
+		"details": []any{},
+	})
+}
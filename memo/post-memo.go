@@ -3,17 +3,64 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"memo/sunbeam"
-	"net/http"
+	"math"
+	"memo/client"
+	"memo/snippet"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
+// maxOutputBytes is the amount of command output kept in the memo.
+const maxOutputBytes = 4096
+
+// commandResult holds the captured output and exit status of a command run.
+type commandResult struct {
+	Output    string
+	Truncated bool
+	ExitCode  int
+	Duration  time.Duration
+	Dir       string
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest.
+// It is safe for concurrent use so stdout and stderr can share it.
+type cappedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	room := c.limit - c.buf.Len()
+	switch {
+	case room <= 0:
+		c.truncated = c.truncated || len(p) > 0
+	case len(p) > room:
+		c.buf.Write(p[:room])
+		c.truncated = true
+	default:
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
 // getLastShellCommand retrieves the last executed command from the shell history
 func getLastShellCommand() (string, error) {
 	// Get the shell's history file (assuming Bash or Zsh)
@@ -28,163 +75,789 @@ func getLastShellCommand() (string, error) {
 		return "", fmt.Errorf("failed to read history file: %w", err)
 	}
 
-	// Split the history file into lines and return the last non-empty line
+	// Split the history file into lines and return the last command that
+	// is not a call to post-memo itself
 	lines := strings.Split(string(data), "\n")
 	for i := len(lines) - 1; i >= 0; i-- {
-		if strings.TrimSpace(lines[i]) != "" {
-			return lines[i], nil
+		command := historyCommand(lines[i])
+		if command != "" && !invokesPostMemo(command) {
+			return command, nil
 		}
 	}
 
 	return "", fmt.Errorf("no commands found in history file")
 }
 
-func main() {
-	// Example path to the Sunbeam configuration file
-	configPath := filepath.Join(os.Getenv("HOME"), ".config", "sunbeam", "sunbeam.json")
-	// Retrieve memo preferences
-	preferences, err := sunbeam.ReadSunbeamConfig(configPath)
+// zshHistoryPrefix matches the ": start:elapsed;" prefix of zsh extended
+// history lines.
+var zshHistoryPrefix = regexp.MustCompile(`^: \d+:\d+;`)
+
+// historyCommand returns the command of a history file line.
+func historyCommand(line string) string {
+	return strings.TrimSpace(zshHistoryPrefix.ReplaceAllString(line, ""))
+}
+
+// invokesPostMemo reports whether a history entry runs post-memo or memo
+// post, which must not be saved or run again.
+func invokesPostMemo(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+	switch filepath.Base(fields[0]) {
+	case "post-memo":
+		return true
+	case "memo":
+		// The command follows the global flags, e.g. memo --profile work post
+		for i := 1; i < len(fields); i++ {
+			switch field := fields[i]; {
+			case field == "-profile" || field == "--profile":
+				i++
+			case strings.HasPrefix(field, "-"):
+			default:
+				return field == "post"
+			}
+		}
+	}
+	return false
+}
+
+// runCommand executes the command, echoing its output to the terminal while
+// capturing it (stdout and stderr interleaved) for the memo.
+func runCommand(name string, args ...string) (commandResult, error) {
+	dir, err := os.Getwd()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		return commandResult{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	apiKey := ""
-	apiURL := ""
-	if len(preferences.MemoToken) == 0 || len(preferences.MemoURL) == 0 {
-		fmt.Printf("Error: no values found in sunbeam memo extension configuration ... trying environment variables")
+	captured := &cappedBuffer{limit: maxOutputBytes}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, captured)
+	cmd.Stderr = io.MultiWriter(os.Stderr, captured)
 
-		apiKey = os.Getenv("USEMEMOS_API_KEY")
-		apiURL = os.Getenv("USEMEMOS_API_URL")
-	} else {
-		apiKey = preferences.MemoToken
-		apiURL = preferences.MemoURL
+	start := time.Now()
+	err = cmd.Run()
+	result := commandResult{
+		Duration: time.Since(start).Round(time.Millisecond),
+		Dir:      dir,
 	}
 
-	if apiKey == "" || apiURL == "" {
-		fmt.Println("Environment variables USEMEMOS_API_KEY and USEMEMOS_API_URL must be set.")
-		os.Exit(1)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return commandResult{}, fmt.Errorf("failed to run command: %w", err)
 	}
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	result.Output = captured.buf.String()
+	result.Truncated = captured.truncated
+	return result, nil
+}
 
-	// Parse command-line arguments
-	tags := flag.String("tags", "", "Comma-separated list of tags for the memo (e.g., 'shell,commands')")
-	flag.Parse()
+// runShellCommand runs a command line from the history through the user's shell.
+func runShellCommand(command string) (commandResult, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return runCommand(shell, "-c", command)
+}
 
-	// Ensure the API URL ends with `/api/memo`
-	if !strings.HasSuffix(apiURL, "/api/v1/memos") {
-		apiURL = strings.TrimRight(apiURL, "/") + "/api/v1/memos"
+// shellJoin joins argv into a command line, quoting words that need it.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`|&;<>(){}*?[]#~!") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
 	}
+	return strings.Join(quoted, " ")
+}
 
-	if apiKey == "" || apiURL == "" {
-		fmt.Println("Environment variables USEMEMOS_API_KEY and USEMEMOS_API_URL must be set.")
-		os.Exit(1)
+// formatOutputSection renders a command result as a collapsible Markdown section.
+func formatOutputSection(result commandResult) string {
+	output := strings.TrimRight(result.Output, "\n")
+	if result.Truncated {
+		output += "\n... (output truncated)"
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\n<details>\n<summary>Output</summary>\n\n")
+	fmt.Fprintf(&b, "- **Exit code:** %d\n", result.ExitCode)
+	fmt.Fprintf(&b, "- **Duration:** %s\n", result.Duration)
+	fmt.Fprintf(&b, "- **Directory:** `%s`\n\n", result.Dir)
+	fmt.Fprintf(&b, "%s\n\n</details>", snippet.Fenced("text", output))
+	return b.String()
+}
+
+// redactedText replaces every secret found in the memo content.
+const redactedText = "[REDACTED]"
+
+// secretRule describes a secret pattern. Only the text matched by group is
+// masked so that the surrounding flag or variable name stays readable.
+type secretRule struct {
+	name  string
+	re    *regexp.Regexp
+	group int
+}
+
+var secretRules = []secretRule{
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), 0},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), 0},
+	{"bearer token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/=-]{8,})`), 1},
+	{"URL credentials", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`), 1},
+	{"secret variable", regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_KEY|PRIVATE_KEY)[A-Z0-9_]*=("[^"\n]*"|'[^'\n]*'|\S+)`), 1},
+	{"secret flag", regexp.MustCompile(`(?i)(?:^|\s)--?(?:token|password|passwd|secret|api-key|apikey|access-key|auth-token)(?:=|\s+)("[^"\n]*"|'[^'\n]*'|\S+)`), 1},
+	// The MySQL clients take the password glued to -p
+	{"MySQL password", regexp.MustCompile(`\b(?:mysql|mysqldump|mysqladmin|mariadb|mariadb-dump)\b[^\n|;&]*?\s-p("[^"\n]*"|'[^'\n]*'|\S+)`), 1},
+}
+
+// highEntropyWord matches long base64/hex-like words that may be credentials.
+var highEntropyWord = regexp.MustCompile(`[A-Za-z0-9+/_-]{20,}={0,2}`)
+
+// digestPrefix matches the algorithm naming a content digest, as in
+// nginx@sha256:<hex>, which is public and not a credential.
+var digestPrefix = regexp.MustCompile(`(?i)\b(?:md5|sha1|sha224|sha256|sha384|sha512):$`)
+
+// secretFinding records one masked value and the rule that caught it.
+type secretFinding struct {
+	Rule  string
+	Value string
+}
+
+// shannonEntropy returns the entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// looksRandom reports whether a word is likely a generated token rather than
+// a path, flag or ordinary identifier.
+func looksRandom(word string) bool {
+	if strings.ContainsAny(word[:1], "/.~-") || strings.Contains(word, redactedText) {
+		return false
+	}
+	hasDigit := strings.ContainsAny(word, "0123456789")
+	hasLetter := strings.IndexFunc(word, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}) >= 0
+	return hasDigit && hasLetter && shannonEntropy(word) >= 4.0
+}
+
+// redactSecrets masks known secret patterns and high-entropy words in text.
+func redactSecrets(text string) (string, []secretFinding) {
+	var findings []secretFinding
+	for _, rule := range secretRules {
+		var b strings.Builder
+		last := 0
+		for _, loc := range rule.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*rule.group], loc[2*rule.group+1]
+			if start < 0 || text[start:end] == redactedText {
+				continue
+			}
+			findings = append(findings, secretFinding{Rule: rule.name, Value: text[start:end]})
+			b.WriteString(text[last:start])
+			b.WriteString(redactedText)
+			last = end
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range highEntropyWord.FindAllStringIndex(text, -1) {
+		word := text[loc[0]:loc[1]]
+		if !looksRandom(word) || digestPrefix.MatchString(text[:loc[0]]) {
+			continue
+		}
+		findings = append(findings, secretFinding{Rule: "high-entropy string", Value: word})
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(redactedText)
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String(), findings
+}
+
+// confirmRedaction shows what would be masked and asks which version to post.
+// It returns false when the user aborts.
+func confirmRedaction(reader *bufio.Reader, content string) (string, bool) {
+	redacted, findings := redactSecrets(content)
+	if len(findings) == 0 {
+		return content, true
+	}
+
+	fmt.Printf("Found %d possible secret(s):\n", len(findings))
+	for _, f := range findings {
+		fmt.Printf("  %s: %s\n", f.Rule, f.Value)
+	}
+
+	// Line-by-line diff; masking never adds or removes lines
+	originalLines := strings.Split(content, "\n")
+	redactedLines := strings.Split(redacted, "\n")
+	for i := range originalLines {
+		if originalLines[i] != redactedLines[i] {
+			fmt.Printf("- %s\n+ %s\n", originalLines[i], redactedLines[i])
+		}
+	}
+
+	fmt.Print("Post with secrets masked? [Y]es / [n]o, abort / [o]riginal: ")
+	answer, _ := reader.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return redacted, true
+	case "o", "original":
+		return content, true
+	default:
+		return "", false
+	}
+}
+
+// parseVisibility maps a visibility name to the value the Memos API expects.
+func parseVisibility(value string) (client.Visibility, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "private":
+		return client.VisibilityPrivate, nil
+	case "protected":
+		return client.VisibilityProtected, nil
+	case "public":
+		return client.VisibilityPublic, nil
+	default:
+		return "", fmt.Errorf("invalid visibility %q (expected private, protected or public)", value)
+	}
+}
+
+// normalizeCommand collapses whitespace so trivially different spellings of
+// the same command compare equal.
+func normalizeCommand(command string) string {
+	command = strings.Join(strings.Fields(command), " ")
+	return strings.TrimRight(command, "; ")
+}
+
+// commandFromContent returns the first code block of a memo's content.
+func commandFromContent(content string) string {
+	return snippet.ExtractCodeBlock(content)
+}
+
+// findDuplicate returns the existing memo holding the same command, if any.
+func findDuplicate(memos []client.Memo, command string) (client.Memo, bool) {
+	want := normalizeCommand(command)
+	for _, memo := range memos {
+		if normalizeCommand(commandFromContent(memo.Content)) == want {
+			return memo, true
+		}
+	}
+	return client.Memo{}, false
+}
+
+// mergeTags adds tags to the existing ones, skipping those already present.
+// The second result reports whether anything was added.
+func mergeTags(existing, tags []string) ([]string, bool) {
+	merged := normalizeTags(existing)
+	seen := make(map[string]bool)
+	for _, tag := range merged {
+		seen[tag] = true
+	}
+	changed := false
+	for _, tag := range normalizeTags(tags) {
+		if !seen[tag] {
+			merged = append(merged, tag)
+			changed = true
+		}
 	}
+	return merged, changed
+}
+
+// spoolEntry is a memo that could not be posted and waits for a retry.
+type spoolEntry struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Payload   json.RawMessage `json:"payload"`
+	// Attempts counts the flushes that found the server unavailable.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// spoolBackoff is the wait before each retry of a spooled memo.
+var spoolBackoff = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
 
-	// Get the last shell command executed
-	lastCommand, err := getLastShellCommand()
+// maxSpoolAttempts is the number of flushes a spooled memo gets before it is
+// set aside as failed, so that it cannot hold back later memos forever.
+const maxSpoolAttempts = 10
+
+// spoolDir returns the directory holding memos waiting to be posted.
+func spoolDir() string {
+	return filepath.Join(stateDir(), "spool")
+}
+
+// spoolPayload saves a memo request so it can be replayed later.
+func spoolPayload(req client.CreateMemoRequest) (string, error) {
+	dir := spoolDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	payload, err := json.Marshal(req)
 	if err != nil {
-		fmt.Printf("Error retrieving last shell command: %v\n", err)
-		os.Exit(1)
+		return "", fmt.Errorf("failed to encode memo: %w", err)
+	}
+	entry, err := json.Marshal(spoolEntry{CreatedAt: time.Now(), Payload: payload})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode spool entry: %w", err)
 	}
 
-	// Trim any trailing newline
-	lastCommand = strings.TrimSpace(lastCommand)
+	// File names sort in creation order, which is the replay order
+	path := filepath.Join(dir, fmt.Sprintf("%020d.json", time.Now().UnixNano()))
+	if err := os.WriteFile(path, entry, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	return path, nil
+}
 
-	// If the last command is empty, exit
-	if lastCommand == "" {
-		fmt.Println("No last shell command found.")
-		os.Exit(1)
+// flushSpool replays spooled memos in order, retrying with backoff while the
+// server is unavailable. It stops at the first memo that still cannot be
+// delivered so that later memos are not posted ahead of it, unless that memo
+// has used up its maxSpoolAttempts flushes. Rejected memos and memos out of
+// attempts are renamed to .failed and skipped.
+func flushSpool(ctx context.Context, c *client.Client) (posted, failed int, err error) {
+	dir := spoolDir()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list spool directory: %w", err)
 	}
+	sort.Strings(paths)
 
-	// Prompt for additional tags
-	fmt.Printf("command: %s \n", lastCommand)
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Enter additional tags (comma-separated): ")
-	additionalTags, _ := reader.ReadString('\n')
-	additionalTags = strings.TrimSpace(additionalTags)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return posted, failed, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var entry spoolEntry
+		var req client.CreateMemoRequest
+		err = json.Unmarshal(data, &entry)
+		if err == nil {
+			err = json.Unmarshal(entry.Payload, &req)
+		}
+		if err != nil {
+			fmt.Printf("  %s: unreadable spool entry (%v), skipped\n", filepath.Base(path), err)
+			os.Rename(path, path+".failed")
+			failed++
+			continue
+		}
 
-	// Combine tags from flag and prompt
-	var allTags []string
-	if *tags != "" {
-		allTags = append(allTags, strings.Split(*tags, ",")...)
+		memo, err := createWithBackoff(ctx, c, req)
+		if ctx.Err() != nil {
+			return posted, failed, ctx.Err()
+		}
+
+		switch {
+		case client.IsUnavailable(err):
+			entry.Attempts++
+			if entry.Attempts >= maxSpoolAttempts {
+				fmt.Printf("  %s: still unreachable after %d attempts, set aside: %v\n", filepath.Base(path), entry.Attempts, err)
+				os.Rename(path, path+".failed")
+				failed++
+				continue
+			}
+			if data, err := json.Marshal(entry); err == nil {
+				os.WriteFile(path, data, 0o600)
+			}
+			return posted, failed, fmt.Errorf("server still unreachable, %d memo(s) left in %s: %w", len(paths)-posted-failed, dir, err)
+		case err != nil:
+			fmt.Printf("  %s: rejected: %v\n", filepath.Base(path), err)
+			os.Rename(path, path+".failed")
+			failed++
+		default:
+			fmt.Printf("  %s: posted (saved %s) %s\n", filepath.Base(path), entry.CreatedAt.Format(time.RFC3339), c.MemoURL(memo))
+			os.Remove(path)
+			posted++
+		}
 	}
-	if additionalTags != "" {
-		allTags = append(allTags, strings.Split(additionalTags, ",")...)
+	return posted, failed, nil
+}
+
+// createWithBackoff creates a memo, retrying after each wait of
+// spoolBackoff while the server is unavailable. It gives up early when ctx
+// is done.
+func createWithBackoff(ctx context.Context, c *client.Client, req client.CreateMemoRequest) (*client.Memo, error) {
+	memo, err := c.Create(ctx, req)
+	for _, wait := range spoolBackoff {
+		if !client.IsUnavailable(err) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		memo, err = c.Create(ctx, req)
+	}
+	return memo, err
+}
+
+// postOrSpool posts req after the memos spooled by earlier runs, so memos
+// are posted in the order they were saved. When those or req cannot be
+// delivered yet, req is spooled behind them and the path of its spool entry
+// is returned instead of the created memo.
+func postOrSpool(ctx context.Context, c *client.Client, req client.CreateMemoRequest) (*client.Memo, string, error) {
+	var flushErr error
+	if paths, _ := filepath.Glob(filepath.Join(spoolDir(), "*.json")); len(paths) > 0 {
+		fmt.Printf("Posting %d spooled memo(s) first...\n", len(paths))
+		var posted, failed int
+		posted, failed, flushErr = flushSpool(ctx, c)
+		fmt.Printf("Flushed spool: %d posted, %d failed.\n", posted, failed)
+		if flushErr != nil {
+			fmt.Printf("Error: %v\n", flushErr)
+		}
+	}
+
+	if flushErr == nil {
+		created, err := c.Create(ctx, req)
+		if err == nil {
+			return created, "", nil
+		}
+		if !client.IsUnavailable(err) {
+			return nil, "", fmt.Errorf("failed to post memo: %w", err)
+		}
+		fmt.Printf("Memos server unreachable (%v).\n", err)
+	}
+	path, err := spoolPayload(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("saving memo for later: %w", err)
+	}
+	return nil, path, nil
+}
+
+// envAssignment matches a leading VAR=value word of a command line.
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// commandWrapper describes a program that runs another program.
+type commandWrapper struct {
+	// args is the number of positional arguments before the real command.
+	args int
+	// valueFlags are the options whose value is a separate word.
+	valueFlags []string
+}
+
+// commandWrappers are the programs programName looks through.
+var commandWrappers = map[string]commandWrapper{
+	"sudo":    {valueFlags: []string{"-u", "-g", "-C", "-D", "-p", "-r", "-t", "-T", "-U", "--user", "--group", "--chdir", "--prompt"}},
+	"doas":    {valueFlags: []string{"-u", "-C"}},
+	"env":     {valueFlags: []string{"-u", "-C", "--unset", "--chdir"}},
+	"time":    {valueFlags: []string{"-f", "-o", "--format", "--output"}},
+	"nohup":   {},
+	"nice":    {valueFlags: []string{"-n", "--adjustment"}},
+	"ionice":  {valueFlags: []string{"-c", "-n", "--class", "--classdata"}},
+	"command": {},
+	"exec":    {valueFlags: []string{"-a"}},
+	"builtin": {},
+	"stdbuf":  {valueFlags: []string{"-i", "-o", "-e"}},
+	"timeout": {args: 1, valueFlags: []string{"-s", "-k", "--signal", "--kill-after"}},
+}
+
+// normalizeTags normalizes tags, dropping empty ones and duplicates while
+// keeping the original order.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, tag := range tags {
+		tag = snippet.NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
 	}
+	return normalized
+}
 
-	// Extract the first word as a tag
-	firstWord := strings.Split(lastCommand, " ")[0]
+// programName returns the program a command line actually runs, skipping
+// environment assignments and wrappers such as sudo, env, time and nohup.
+// Paths and extensions are dropped, so ./run.sh becomes run.
+func programName(command string) string {
+	words := strings.Fields(command)
+	skipArgs := 0
+	var wrapper *commandWrapper
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if w, ok := commandWrappers[word]; ok {
+			wrapper = &w
+			skipArgs = w.args
+			continue
+		}
+		if envAssignment.MatchString(word) {
+			continue
+		}
+		if wrapper != nil && strings.HasPrefix(word, "-") {
+			if slices.Contains(wrapper.valueFlags, word) {
+				i++
+			}
+			continue
+		}
+		if skipArgs > 0 {
+			skipArgs--
+			continue
+		}
 
-	// Prefix tags with # and format as Markdown
-	var hashtags []string
+		name := filepath.Base(word)
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return ""
+}
 
-	//add default tags
-	hashtags = append(hashtags, "#cmd")
-	hashtags = append(hashtags, "#"+firstWord)
+// tagsByUsage orders the tags reported by the server, most used first.
+func tagsByUsage(amounts map[string]int) []string {
+	counts := make(map[string]int)
+	for tag, n := range amounts {
+		counts[snippet.NormalizeTag(tag)] += n
+	}
 
-	for _, tag := range allTags {
-		tag = strings.TrimSpace(tag)
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
 		if tag != "" {
-			hashtags = append(hashtags, "#"+tag)
+			tags = append(tags, tag)
 		}
 	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags
+}
 
-	tagsMarkdown := ""
-	if len(hashtags) > 0 {
-		tagsMarkdown = "\n\n**Tags:**\n" + strings.Join(hashtags, " ")
+// completeTag expands a tag ending in * to the known tags starting with it.
+func completeTag(tag string, knownTags []string) []string {
+	prefix, ok := strings.CutSuffix(tag, "*")
+	if !ok {
+		return []string{tag}
 	}
+	prefix = snippet.NormalizeTag(prefix)
 
-	// Create Markdown content
-	markdownContent := fmt.Sprintf("```shell\n%s\n```%s", lastCommand, tagsMarkdown)
+	var matches []string
+	for _, known := range knownTags {
+		if strings.HasPrefix(known, prefix) {
+			matches = append(matches, known)
+		}
+	}
+	if len(matches) == 0 {
+		return []string{prefix}
+	}
+	return matches
+}
 
-	// Create memo payload
-	memo := map[string]interface{}{
-		"content":    markdownContent,
-		"visibility": "PUBLIC", // Default visibility
+// promptTags asks for additional tags, showing the most used existing tags.
+// A tag ending in * is completed from the existing tags; when the prefix is
+// ambiguous the candidates are shown and the prompt is repeated.
+func promptTags(reader *bufio.Reader, knownTags []string) []string {
+	if len(knownTags) > 0 {
+		shown := knownTags
+		if len(shown) > 15 {
+			shown = shown[:15]
+		}
+		fmt.Printf("Existing tags: %s\n", strings.Join(shown, ", "))
 	}
-	if len(allTags) > 0 {
-		memo["tags"] = allTags
+
+	for {
+		fmt.Print("Enter additional tags (comma-separated, end with * to complete): ")
+		line, err := reader.ReadString('\n')
+
+		var tags []string
+		ambiguous := false
+		for _, tag := range strings.Split(line, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			matches := completeTag(tag, knownTags)
+			if len(matches) > 1 {
+				fmt.Printf("  %s matches: %s\n", tag, strings.Join(matches, ", "))
+				ambiguous = true
+				continue
+			}
+			if matches[0] != tag {
+				fmt.Printf("  %s -> %s\n", tag, matches[0])
+			}
+			tags = append(tags, matches[0])
+		}
+		if !ambiguous || err != nil {
+			return tags
+		}
 	}
+}
+
+// postMemo implements memo post, the former post-memo command: it saves
+// the last shell command, or the command given after --, as a memo.
+func postMemo(args []string, profile string) {
+	// Parse command-line arguments
+	fs := flag.NewFlagSet("post", flag.ExitOnError)
+	tags := fs.String("tags", "", "Comma-separated list of tags for the memo (e.g., 'shell,commands')")
+	run := fs.Bool("run", false, "Re-run the last shell command and include its output in the memo")
+	flush := fs.Bool("flush", false, "Post memos saved while the server was unreachable and exit")
+	visibilityFlag := fs.String("visibility", "", "Memo visibility: private, protected or public (default from sunbeam config, USEMEMOS_VISIBILITY, or private)")
+	fs.StringVar(&profile, "profile", profile, profileUsage)
+	fs.Parse(args)
 
-	payload, err := json.Marshal(memo)
+	c, preferences, err := newClient(profile)
 	if err != nil {
-		fmt.Printf("Error creating request payload: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	ctx := context.Background()
+	reader := bufio.NewReader(os.Stdin)
 
-	// Create HTTP client and request
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(payload))
+	// Visibility falls back from the flag to the config or environment, then PRIVATE
+	visibilityName := *visibilityFlag
+	if visibilityName == "" {
+		visibilityName = preferences.MemoVisibility
+	}
+	if visibilityName == "" {
+		visibilityName = "private"
+	}
+	visibility, err := parseVisibility(visibilityName)
 	if err != nil {
-		fmt.Printf("Error creating HTTP request: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Add headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	if *flush {
+		posted, failed, err := flushSpool(ctx, c)
+		fmt.Printf("Flushed spool: %d posted, %d failed.\n", posted, failed)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Send request
-	resp, err := client.Do(req)
+	// Commands given after `--` are run directly, otherwise use the last shell command
+	var lastCommand string
+	var result *commandResult
+	if args := fs.Args(); len(args) > 0 {
+		lastCommand = shellJoin(args)
+		r, err := runCommand(args[0], args[1:]...)
+		if err != nil {
+			fmt.Printf("Error running command: %v\n", err)
+			os.Exit(1)
+		}
+		result = &r
+	} else {
+		// Get the last shell command executed
+		lastCommand, err = getLastShellCommand()
+		if err != nil {
+			fmt.Printf("Error retrieving last shell command: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Trim any trailing newline
+		lastCommand = strings.TrimSpace(lastCommand)
+
+		// If the last command is empty, exit
+		if lastCommand == "" {
+			fmt.Println("No last shell command found.")
+			os.Exit(1)
+		}
+
+		// The history may hold a stale command from another session, so
+		// it is only run once confirmed
+		if *run {
+			fmt.Printf("command: %s \n", lastCommand)
+			if !confirm(reader, os.Stdout, "Run this command?") {
+				fmt.Println("Aborted, nothing was run or posted.")
+				os.Exit(1)
+			}
+			r, err := runShellCommand(lastCommand)
+			if err != nil {
+				fmt.Printf("Error running command: %v\n", err)
+				os.Exit(1)
+			}
+			result = &r
+		}
+	}
+
+	// Existing #cmd memos feed both tag suggestions and duplicate detection
+	existing, err := c.ListAll(ctx, "tag_search == ['cmd']")
+	if err != nil {
+		fmt.Printf("Warning: could not fetch existing memos: %v\n", err)
+	}
+	tagAmounts, err := c.ListTags(ctx, "")
 	if err != nil {
-		fmt.Printf("Error sending request: %v\n", err)
+		fmt.Printf("Warning: could not fetch existing tags: %v\n", err)
+	}
+	knownTags := tagsByUsage(tagAmounts)
+
+	// Prompt for additional tags
+	fmt.Printf("command: %s \n", lastCommand)
+	additionalTags := promptTags(reader, knownTags)
+
+	// Combine tags from flag and prompt
+	var allTags []string
+	if *tags != "" {
+		allTags = append(allTags, strings.Split(*tags, ",")...)
+	}
+	allTags = append(allTags, additionalTags...)
+
+	// Default tags come first, then the program being run (skipping sudo and friends)
+	allTags = normalizeTags(append([]string{"cmd", programName(lastCommand)}, allTags...))
+
+	outputMarkdown := ""
+	if result != nil {
+		outputMarkdown = formatOutputSection(*result)
+	}
+
+	// Create Markdown content, with the tags formatted as hashtags at the end
+	markdownContent := snippet.ReplaceTags(snippet.Fenced("shell", lastCommand)+outputMarkdown, allTags)
+
+	// Mask secrets before anything leaves the machine
+	markdownContent, ok := confirmRedaction(reader, markdownContent)
+	if !ok {
+		fmt.Println("Aborted, nothing was posted.")
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	// Offer to retag an existing memo instead of posting the same command twice
+	if duplicate, found := findDuplicate(existing, commandFromContent(markdownContent)); found {
+		fmt.Printf("This command is already saved in %s.\n", duplicate.Name)
+		if confirm(reader, os.Stdout, "Add the new tags to the existing memo instead?") {
+			merged, changed := mergeTags(snippet.ExtractTags(duplicate.Content), allTags)
+			if !changed {
+				fmt.Println("Existing memo already has these tags, nothing to do.")
+				return
+			}
+			if _, err := c.SetTags(ctx, duplicate.Name, merged); err != nil {
+				fmt.Printf("Error updating memo: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Memo updated successfully!")
+			return
+		}
+	}
+
+	// Create memo payload
+	req := client.CreateMemoRequest{
+		Content:    markdownContent,
+		Visibility: visibility,
+	}
+
+	created, path, err := postOrSpool(ctx, c, req)
 	if err != nil {
-		fmt.Printf("Error reading response: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Output response
-	if resp.StatusCode == http.StatusOK {
-		fmt.Println("Memo posted successfully!")
-		//fmt.Printf("Response: %s\n", string(body))
-	} else {
-		fmt.Printf("Failed to post memo. Status: %s\n", resp.Status)
-		fmt.Printf("Response: %s\n", string(body))
+	if created == nil {
+		fmt.Printf("Memo saved to %s, run memo post --flush to retry.\n", path)
+		return
 	}
+
+	fmt.Println("Memo posted successfully!")
+	fmt.Println(c.MemoURL(created))
 }
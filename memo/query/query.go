@@ -0,0 +1,400 @@
+// Package query implements the search language of get-memos, evaluated
+// locally against parsed command memos:
+//
+//	tag:k8s AND NOT tag:old "rollout restart"
+//
+// Terms are tag:NAME (exact tag match), lang:NAME (code block language),
+// "quoted phrases" (case-insensitive substring of the command) and bare
+// words (fuzzy match against the command). Terms combine with AND, OR and
+// NOT, group with parentheses, and adjacent terms are joined with AND.
+// A leading - or ! is shorthand for NOT.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Doc is what a query is evaluated against: one command of a memo.
+type Doc struct {
+	Command string
+	Tags    []string
+	Lang    string
+}
+
+// JoinArgs joins command-line arguments into a query, quoting arguments
+// that contain spaces so they stay phrases.
+func JoinArgs(args []string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t") && !strings.Contains(arg, `"`) {
+			arg = `"` + arg + `"`
+		}
+		words[i] = arg
+	}
+	return strings.Join(words, " ")
+}
+
+// Query is a parsed search expression.
+type Query struct {
+	root node
+}
+
+// node is an expression of the query. eval reports whether the document
+// matches and how well, higher scores being better matches.
+type node interface {
+	eval(doc *Doc) (score int, ok bool)
+}
+
+// Parse parses a query. The empty query matches everything.
+func Parse(input string) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if len(tokens) == 0 {
+		return &Query{root: matchAll{}}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	return &Query{root: root}, nil
+}
+
+// Match reports whether doc matches the query and its relevance score.
+func (q *Query) Match(doc Doc) (int, bool) {
+	return q.root.eval(&doc)
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokPhrase
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t *token) String() string {
+	switch t.kind {
+	case tokPhrase:
+		return fmt.Sprintf("%q", t.value)
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	}
+	return t.value
+}
+
+// lex splits the input into tokens.
+func lex(input string) ([]*token, error) {
+	var tokens []*token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, &token{kind: tokLParen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, &token{kind: tokRParen, pos: i})
+			i++
+		case (r == '-' || r == '!') && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, &token{kind: tokNot, value: string(r), pos: i})
+			i++
+		case r == '"':
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated quote at position %d", start)
+			}
+			i++
+			tokens = append(tokens, &token{kind: tokPhrase, value: b.String(), pos: start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				// tag:"a b" keeps the quoted value in the word
+				if runes[i] == '"' {
+					end := i + 1
+					for end < len(runes) && runes[end] != '"' {
+						end++
+					}
+					if end == len(runes) {
+						return nil, fmt.Errorf("unterminated quote at position %d", i)
+					}
+					i = end
+				}
+				i++
+			}
+			word := string(runes[start:i])
+			tok := &token{kind: tokWord, value: word, pos: start}
+			switch word {
+			case "AND", "and", "&&":
+				tok.kind = tokAnd
+			case "OR", "or", "||":
+				tok.kind = tokOr
+			case "NOT", "not", "!":
+				tok.kind = tokNot
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []*token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) next() *token {
+	tok := p.peek()
+	if tok != nil {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr parses: and ("OR" and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok != nil && tok.kind == tokOr; tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary (["AND"] unary)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind == tokOr || tok.kind == tokRParen {
+			return left, nil
+		}
+		if tok.kind == tokAnd {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+// parseUnary parses: "NOT" unary | "(" or ")" | term
+func (p *parser) parseUnary() (node, error) {
+	tok := p.next()
+	if tok == nil {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	switch tok.kind {
+	case tokNot:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing == nil || closing.kind != tokRParen {
+			return nil, fmt.Errorf("missing ) for ( at position %d", tok.pos)
+		}
+		return inner, nil
+	case tokPhrase:
+		return phraseNode(strings.ToLower(tok.value)), nil
+	case tokWord:
+		return termNode(tok)
+	default:
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+}
+
+// termNode turns a word into a field term or a fuzzy word.
+func termNode(tok *token) (node, error) {
+	field, value, ok := strings.Cut(tok.value, ":")
+	if ok {
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(field) {
+		case "tag":
+			value = strings.TrimPrefix(value, "#")
+			if value == "" {
+				return nil, fmt.Errorf("empty tag at position %d", tok.pos)
+			}
+			return tagNode(value), nil
+		case "lang":
+			return langNode(strings.ToLower(value)), nil
+		}
+	}
+	return fuzzyNode(strings.ToLower(tok.value)), nil
+}
+
+// Scored reports whether matches can score differently, which only words
+// and phrases outside of NOT do. Without them every match scores the same.
+func (q *Query) Scored() bool {
+	return scored(q.root)
+}
+
+func scored(n node) bool {
+	switch n := n.(type) {
+	case andNode:
+		return scored(n.left) || scored(n.right)
+	case orNode:
+		return scored(n.left) || scored(n.right)
+	case phraseNode, fuzzyNode:
+		return true
+	}
+	return false
+}
+
+type matchAll struct{}
+
+func (matchAll) eval(*Doc) (int, bool) { return 0, true }
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(doc *Doc) (int, bool) {
+	ls, ok := n.left.eval(doc)
+	if !ok {
+		return 0, false
+	}
+	rs, ok := n.right.eval(doc)
+	if !ok {
+		return 0, false
+	}
+	return ls + rs, true
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(doc *Doc) (int, bool) {
+	ls, lok := n.left.eval(doc)
+	rs, rok := n.right.eval(doc)
+	return max(ls, rs), lok || rok
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(doc *Doc) (int, bool) {
+	_, ok := n.operand.eval(doc)
+	return 0, !ok
+}
+
+// tagNode matches a tag exactly, ignoring case.
+type tagNode string
+
+func (n tagNode) eval(doc *Doc) (int, bool) {
+	for _, tag := range doc.Tags {
+		if strings.EqualFold(tag, string(n)) {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// langNode matches the language of the code block.
+type langNode string
+
+func (n langNode) eval(doc *Doc) (int, bool) {
+	return 0, strings.EqualFold(doc.Lang, string(n))
+}
+
+// phraseNode matches a case-insensitive substring of the command.
+type phraseNode string
+
+func (n phraseNode) eval(doc *Doc) (int, bool) {
+	if strings.Contains(strings.ToLower(doc.Command), string(n)) {
+		return 100, true
+	}
+	return 0, false
+}
+
+// fuzzyNode matches when its letters appear in order in the command.
+type fuzzyNode string
+
+func (n fuzzyNode) eval(doc *Doc) (int, bool) {
+	return FuzzyScore(doc.Command, string(n))
+}
+
+// FuzzyScore reports whether the runes of pattern appear in order in text,
+// ignoring case. Substrings score highest; otherwise the score drops with
+// the number of characters skipped between matched runes.
+func FuzzyScore(text, pattern string) (int, bool) {
+	text = strings.ToLower(text)
+	pattern = strings.ToLower(pattern)
+	if pattern == "" {
+		return 0, true
+	}
+	if strings.Contains(text, pattern) {
+		return 100, true
+	}
+
+	gaps := 0
+	pi := 0
+	p := []rune(pattern)
+	started := false
+	for _, r := range text {
+		if pi == len(p) {
+			break
+		}
+		if r == p[pi] {
+			pi++
+			started = true
+		} else if started {
+			gaps++
+		}
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+	return max(90-gaps, 1), true
+}
@@ -0,0 +1,134 @@
+package query
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	rollout := Doc{Command: "kubectl -n prod rollout restart deploy/api", Tags: []string{"cmd", "kubectl", "k8s"}, Lang: "shell"}
+	old := Doc{Command: "kubectl rollout restart deploy/web", Tags: []string{"cmd", "k8s", "old"}, Lang: "shell"}
+	github := Doc{Command: "gh pr list", Tags: []string{"cmd", "github"}, Lang: "bash"}
+	sql := Doc{Command: "SELECT * FROM users;", Tags: []string{"db"}, Lang: "sql"}
+	docs := map[string]Doc{"rollout": rollout, "old": old, "github": github, "sql": sql}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{``, []string{"rollout", "old", "github", "sql"}},
+		{`tag:k8s`, []string{"rollout", "old"}},
+		{`tag:K8S`, []string{"rollout", "old"}},
+		{`tag:#k8s`, []string{"rollout", "old"}},
+		{`tag:git`, nil},
+		{`tag:k8s AND NOT tag:old "rollout restart"`, []string{"rollout"}},
+		{`tag:k8s -tag:old`, []string{"rollout"}},
+		{`tag:k8s !tag:old`, []string{"rollout"}},
+		{`tag:github OR tag:db`, []string{"github", "sql"}},
+		{`(tag:github OR tag:db) lang:sql`, []string{"sql"}},
+		{`tag:k8s AND (prod OR web)`, []string{"rollout", "old"}},
+		{`"ROLLOUT RESTART"`, []string{"rollout", "old"}},
+		{`"restart deploy/api"`, []string{"rollout"}},
+		{`krr`, []string{"rollout", "old"}},
+		{`kbctl rstrt`, []string{"rollout", "old"}},
+		{`zzz`, nil},
+		{`NOT tag:cmd`, []string{"sql"}},
+		{`lang:bash`, []string{"github"}},
+		{`tag:k8s and not tag:old`, []string{"rollout"}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		for name, doc := range docs {
+			_, got := q.Match(doc)
+			want := false
+			for _, w := range tt.want {
+				want = want || w == name
+			}
+			if got != want {
+				t.Errorf("Parse(%q).Match(%s) = %v, want %v", tt.query, name, got, want)
+			}
+		}
+	}
+}
+
+func TestScored(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{``, false},
+		{`tag:k8s lang:shell`, false},
+		{`tag:k8s -rollout`, false},
+		{`NOT "rollout restart"`, false},
+		{`tag:k8s rollout`, true},
+		{`tag:db OR "select"`, true},
+		{`(tag:k8s kbctl)`, true},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		if got := q.Scored(); got != tt.want {
+			t.Errorf("Parse(%q).Scored() = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		`"unterminated`,
+		`tag:k8s AND`,
+		`(tag:k8s`,
+		`tag:k8s)`,
+		`NOT`,
+		`tag:`,
+		`OR tag:k8s`,
+	} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		text, pattern string
+		wantOK        bool
+	}{
+		{"kubectl get pods", "kgp", true},
+		{"kubectl get pods", "get", true},
+		{"kubectl get pods", "pgk", false},
+		{"docker ps", "", true},
+		{"Docker PS", "dps", true},
+	}
+	for _, tt := range tests {
+		if _, ok := FuzzyScore(tt.text, tt.pattern); ok != tt.wantOK {
+			t.Errorf("FuzzyScore(%q, %q) ok = %v, want %v", tt.text, tt.pattern, ok, tt.wantOK)
+		}
+	}
+
+	substring, _ := FuzzyScore("kubectl get pods", "get")
+	scattered, _ := FuzzyScore("kubectl get pods", "kgp")
+	tight, _ := FuzzyScore("kgp", "kgp")
+	if substring <= scattered || tight <= scattered {
+		t.Errorf("substring scores %d and %d should beat scattered %d", substring, tight, scattered)
+	}
+}
+
+func TestJoinArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"tag:k8s", "rollout"}, "tag:k8s rollout"},
+		{[]string{"rollout restart", "tag:k8s"}, `"rollout restart" tag:k8s`},
+		{[]string{`"already quoted" phrase`}, `"already quoted" phrase`},
+	}
+	for _, tt := range tests {
+		if got := JoinArgs(tt.args); got != tt.want {
+			t.Errorf("JoinArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
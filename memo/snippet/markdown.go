@@ -0,0 +1,143 @@
+package snippet
+
+import (
+	"strings"
+)
+
+// CodeBlock is a fenced code block of a memo.
+type CodeBlock struct {
+	// Lang is the first word of the info string, e.g. shell, sql or yaml.
+	Lang string
+	// Info is the full info string following the opening fence.
+	Info string
+	Code string
+	// InDetails is set for blocks inside a <details> section, such as the
+	// command output post-memo records with --run.
+	InDetails bool
+}
+
+// shellLangs are the info strings treated as shell code.
+var shellLangs = map[string]bool{
+	"shell": true, "sh": true, "bash": true, "zsh": true, "fish": true,
+	"console": true, "shell-session": true, "shellsession": true,
+}
+
+// MatchesLang reports whether the block is written in lang. The shell
+// languages (shell, sh, bash, zsh...) all match each other, and blocks
+// without an info string match "shell" too since post-memo once wrote them.
+func (b CodeBlock) MatchesLang(lang string) bool {
+	lang = strings.ToLower(lang)
+	have := strings.ToLower(b.Lang)
+	if shellLangs[lang] {
+		return have == "" || shellLangs[have]
+	}
+	return have == lang
+}
+
+// Fenced returns code as a fenced code block with the info string. The
+// fence is longer than any backtick run in code, so code holding a fence of
+// its own, such as command output printing Markdown, cannot close the block.
+func Fenced(info, code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + info + "\n" + code + "\n" + fence
+}
+
+// openingFence parses a fence opening line: up to three spaces of indent,
+// three or more backticks or tildes, then the info string. It returns the
+// fence, the indent and the info string.
+func openingFence(line string) (fence string, indent int, info string, ok bool) {
+	indent = len(line) - len(strings.TrimLeft(line, " "))
+	if indent > 3 {
+		return "", 0, "", false
+	}
+	rest := line[indent:]
+	if !strings.HasPrefix(rest, "```") && !strings.HasPrefix(rest, "~~~") {
+		return "", 0, "", false
+	}
+	n := len(rest) - len(strings.TrimLeft(rest, rest[:1]))
+	fence, info = rest[:n], strings.TrimSpace(rest[n:])
+
+	// Backtick fences cannot have backticks in the info string
+	if fence[0] == '`' && strings.Contains(info, "`") {
+		return "", 0, "", false
+	}
+	return fence, indent, info, true
+}
+
+// closesFence reports whether line closes a block opened with fence: the same
+// character repeated at least as many times, with nothing else on the line.
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 || len(trimmed) < len(fence) {
+		return false
+	}
+	return strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// ExtractCodeBlocks returns every fenced code block of the content, in
+// order. A block left open runs to the end of the content.
+func ExtractCodeBlocks(content string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var code []string
+	fence, indent := "", 0
+	inDetails := false
+
+	for _, line := range strings.Split(content, "\n") {
+		if current != nil {
+			if closesFence(line, fence) {
+				current.Code = strings.Join(code, "\n")
+				blocks = append(blocks, *current)
+				current = nil
+				continue
+			}
+			// Content lines lose up to the indent of the opening fence
+			for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+				line = line[1:]
+			}
+			code = append(code, line)
+			continue
+		}
+
+		if f, n, info, ok := openingFence(line); ok {
+			lang, _, _ := strings.Cut(info, " ")
+			current = &CodeBlock{Lang: lang, Info: info, InDetails: inDetails}
+			code = nil
+			fence, indent = f, n
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "<details") {
+			inDetails = true
+		} else if strings.HasPrefix(trimmed, "</details>") {
+			inDetails = false
+		}
+	}
+
+	if current != nil {
+		current.Code = strings.TrimRight(strings.Join(code, "\n"), "\n")
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// CommandBlocks returns the code blocks that hold commands, leaving out
+// recorded output and other blocks inside <details> sections. When lang is
+// set only blocks in that language are returned.
+func CommandBlocks(content, lang string) []CodeBlock {
+	var blocks []CodeBlock
+	for _, b := range ExtractCodeBlocks(content) {
+		if b.InDetails || strings.TrimSpace(b.Code) == "" {
+			continue
+		}
+		if lang != "" && !b.MatchesLang(lang) {
+			continue
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
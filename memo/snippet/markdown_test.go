@@ -0,0 +1,120 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []CodeBlock
+	}{
+		{
+			name:    "several languages",
+			content: "Setup:\n\n```shell\nmake db\n```\n\nthen\n\n```sql title=\"q\"\nSELECT 1;\n```\n\n~~~yaml\na: b\n~~~",
+			want: []CodeBlock{
+				{Lang: "shell", Info: "shell", Code: "make db"},
+				{Lang: "sql", Info: `sql title="q"`, Code: "SELECT 1;"},
+				{Lang: "yaml", Info: "yaml", Code: "a: b"},
+			},
+		},
+		{
+			name:    "no info string",
+			content: "```\nls\n```",
+			want:    []CodeBlock{{Code: "ls"}},
+		},
+		{
+			name:    "longer fence holds shorter one",
+			content: "````markdown\n```shell\nls\n```\n````",
+			want:    []CodeBlock{{Lang: "markdown", Info: "markdown", Code: "```shell\nls\n```"}},
+		},
+		{
+			name:    "tilde does not close backticks",
+			content: "```\na\n~~~\nb\n```",
+			want:    []CodeBlock{{Code: "a\n~~~\nb"}},
+		},
+		{
+			name:    "indented fence",
+			content: "  ```sh\n  ls\n    -la\n  ```",
+			want:    []CodeBlock{{Lang: "sh", Info: "sh", Code: "ls\n  -la"}},
+		},
+		{
+			name:    "unterminated",
+			content: "```bash\necho hi\n",
+			want:    []CodeBlock{{Lang: "bash", Info: "bash", Code: "echo hi"}},
+		},
+		{
+			name:    "empty block",
+			content: "```\n```",
+			want:    []CodeBlock{{Code: ""}},
+		},
+		{
+			name:    "details output",
+			content: "```shell\nls\n```\n\n<details>\n<summary>Output</summary>\n\n```text\na.txt\n```\n\n</details>",
+			want: []CodeBlock{
+				{Lang: "shell", Info: "shell", Code: "ls"},
+				{Lang: "text", Info: "text", Code: "a.txt", InDetails: true},
+			},
+		},
+		{
+			name:    "inline backticks are not fences",
+			content: "use ``` to fence, e.g. ```sh`x`\nls",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractCodeBlocks(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractCodeBlocks =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandBlocks(t *testing.T) {
+	content := "```bash\nls\n```\n\n```sql\nSELECT 1;\n```\n\n```\npwd\n```\n\n<details>\n\n```text\nout\n```\n\n</details>"
+
+	tests := []struct {
+		lang string
+		want []string
+	}{
+		{"", []string{"ls", "SELECT 1;", "pwd"}},
+		{"shell", []string{"ls", "pwd"}},
+		{"bash", []string{"ls", "pwd"}},
+		{"SQL", []string{"SELECT 1;"}},
+		{"text", nil},
+		{"yaml", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, b := range CommandBlocks(content, tt.lang) {
+			got = append(got, b.Code)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CommandBlocks(%q) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestFenced(t *testing.T) {
+	tests := []struct {
+		info, code string
+		want       string
+	}{
+		{"shell", "ls", "```shell\nls\n```"},
+		{"", "out", "```\nout\n```"},
+		{"text", "```go\nx\n```", "````text\n```go\nx\n```\n````"},
+		{"text", "`````", "``````text\n`````\n``````"},
+	}
+	for _, tt := range tests {
+		got := Fenced(tt.info, tt.code)
+		if got != tt.want {
+			t.Errorf("Fenced(%q, %q) = %q, want %q", tt.info, tt.code, got, tt.want)
+		}
+		if blocks := ExtractCodeBlocks(got); len(blocks) != 1 || blocks[0].Code != tt.code {
+			t.Errorf("Fenced(%q, %q) parses as %+v", tt.info, tt.code, blocks)
+		}
+	}
+}
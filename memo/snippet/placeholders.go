@@ -0,0 +1,44 @@
+package snippet
+
+import "regexp"
+
+// placeholderPattern matches {{name}} and <name> parameters. The angle
+// bracket form only accepts a bare name so redirections such as "< file" and
+// process substitutions such as "<(cmd)" are left alone.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w.-]*)\s*\}\}|<([A-Za-z_][\w-]*)>`)
+
+// placeholderName returns the name of a placeholder match.
+func placeholderName(match []string) string {
+	if match[1] != "" {
+		return match[1]
+	}
+	return match[2]
+}
+
+// Placeholders returns the names of the parameters of a saved command, such
+// as namespace and pod in "kubectl -n {{namespace}} logs <pod>", in order of
+// first appearance.
+func Placeholders(command string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(command, -1) {
+		name := placeholderName(match)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// FillPlaceholders replaces every parameter of command with its value.
+// Parameters without a value are kept as written.
+func FillPlaceholders(command string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(command, func(s string) string {
+		name := placeholderName(placeholderPattern.FindStringSubmatch(s))
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return s
+	})
+}
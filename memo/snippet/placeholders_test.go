@@ -0,0 +1,48 @@
+package snippet
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"braces", "kubectl -n {{namespace}} logs {{pod}}", []string{"namespace", "pod"}},
+		{"angle brackets", "ssh <user>@<host>", []string{"user", "host"}},
+		{"mixed and repeated", "cp {{src}} <dst> && ls {{ src }}", []string{"src", "dst"}},
+		{"dotted name", "echo {{db.name}}", []string{"db.name"}},
+		{"redirections", "sort < in.txt > out.txt", nil},
+		{"process substitution", "diff <(ls a) <(ls b)", nil},
+		{"heredoc", "cat <<EOF\nhi\nEOF", nil},
+		{"no placeholders", "ls -la", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Placeholders(tt.command); !slices.Equal(got, tt.want) {
+				t.Errorf("Placeholders(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillPlaceholders(t *testing.T) {
+	values := map[string]string{"namespace": "prod", "pod": "web-1", "src": "a b"}
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"kubectl -n {{namespace}} logs <pod>", "kubectl -n prod logs web-1"},
+		{"cp {{ src }} {{src}}", "cp a b a b"},
+		{"echo {{missing}} <other>", "echo {{missing}} <other>"},
+		{"sort < in.txt", "sort < in.txt"},
+	}
+	for _, tt := range tests {
+		if got := FillPlaceholders(tt.command, values); got != tt.want {
+			t.Errorf("FillPlaceholders(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package snippet parses the command memos written by post-memo: a fenced
+// code block holding the command followed by a **Tags:** section.
+package snippet
+
+// ExtractCodeBlock returns the code of the first fenced code block.
+func ExtractCodeBlock(content string) string {
+	blocks := ExtractCodeBlocks(content)
+	if len(blocks) == 0 {
+		return ""
+	}
+	return blocks[0].Code
+}
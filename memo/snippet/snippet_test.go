@@ -0,0 +1,27 @@
+package snippet
+
+import "testing"
+
+func TestExtractCodeBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"post-memo format", "```shell\nkubectl get pods\n```\n\n**Tags:**\n#cmd", "kubectl get pods"},
+		{"no language", "```\nls -la\n```", "ls -la"},
+		{"multi-line", "```bash\nset -e\nmake\n```", "set -e\nmake"},
+		{"first block only", "```sh\none\n```\n\n```sh\ntwo\n```", "one"},
+		{"no block", "just text #cmd", ""},
+		{"unterminated", "```shell\nls", "ls"},
+		{"no trailing newline", "```shell\nls\n```", "ls"},
+		{"tilde fence", "~~~sh\nls\n~~~", "ls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractCodeBlock(tt.content); got != tt.want {
+				t.Errorf("ExtractCodeBlock = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
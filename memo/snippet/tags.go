@@ -0,0 +1,107 @@
+package snippet
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tagsHeading starts the tags section written by post-memo.
+const tagsHeading = "**Tags:**"
+
+var hashtagRe = regexp.MustCompile(`^#[^\s#]+$`)
+
+// tagInvalidChars matches everything the Memos tag syntax does not accept.
+var tagInvalidChars = regexp.MustCompile(`[^\p{L}\p{N}_/-]+`)
+
+// NormalizeTag turns a tag typed by the user or taken from another tool
+// into a Memos tag: lowercase, without the leading #, and with runs of
+// characters Memos does not accept replaced by a dash, so "My Tag" becomes
+// my-tag. It returns "" when nothing is left.
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimLeft(strings.TrimSpace(tag), "#"))
+	return strings.Trim(tagInvalidChars.ReplaceAllString(tag, "-"), "-")
+}
+
+// hashtags returns the tags of a line made only of hashtags, without the #,
+// and false for any other line.
+func hashtags(line string) ([]string, bool) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return nil, false
+	}
+	tags := make([]string, len(words))
+	for i, word := range words {
+		if !hashtagRe.MatchString(word) {
+			return nil, false
+		}
+		tags[i] = strings.TrimPrefix(word, "#")
+	}
+	return tags, true
+}
+
+// tagsSection locates the first **Tags:** section outside fenced code. It
+// returns the range of lines it spans, with start -1 when there is none, and
+// the tags it lists. The tags follow the heading on the same line or on the
+// hashtag-only lines after it.
+func tagsSection(lines []string) (start, end int, tags []string) {
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		if fence != "" {
+			if closesFence(lines[i], fence) {
+				fence = ""
+			}
+			continue
+		}
+		if f, _, _, ok := openingFence(lines[i]); ok {
+			fence = f
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), tagsHeading)
+		if !ok {
+			continue
+		}
+		tags, _ = hashtags(rest)
+		end = i + 1
+		for end < len(lines) {
+			next := strings.TrimSpace(lines[end])
+			if next == "" && len(tags) == 0 {
+				end++
+				continue
+			}
+			more, ok := hashtags(next)
+			if !ok {
+				break
+			}
+			tags = append(tags, more...)
+			end++
+		}
+		return i, end, tags
+	}
+	return -1, -1, nil
+}
+
+// ExtractTags parses the tags from the **Tags:** section, ignoring anything
+// inside fenced code blocks.
+func ExtractTags(content string) []string {
+	_, _, tags := tagsSection(strings.Split(content, "\n"))
+	return tags
+}
+
+// ReplaceTags rewrites the **Tags:** section to list tags, appending the
+// section when the content has none.
+func ReplaceTags(content string, tags []string) string {
+	hashtags := make([]string, len(tags))
+	for i, tag := range tags {
+		hashtags[i] = "#" + strings.TrimPrefix(tag, "#")
+	}
+	section := []string{tagsHeading, strings.Join(hashtags, " ")}
+
+	lines := strings.Split(content, "\n")
+	start, end, _ := tagsSection(lines)
+	if start < 0 {
+		return strings.TrimRight(content, "\n") + "\n\n" + strings.Join(section, "\n")
+	}
+	lines = append(lines[:start], append(section, lines[end:]...)...)
+	return strings.Join(lines, "\n")
+}
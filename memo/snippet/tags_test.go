@@ -0,0 +1,79 @@
+package snippet
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"post-memo format", "```shell\nls\n```\n\n**Tags:**\n#cmd #ls #files", []string{"cmd", "ls", "files"}},
+		{"same line", "**Tags:** #a #b-c #d_e", []string{"a", "b-c", "d_e"}},
+		{"dashes and slashes", "**Tags:**\n#cmd #docker-compose #k8s/prod", []string{"cmd", "docker-compose", "k8s/prod"}},
+		{"hashtag lines", "**Tags:**\n#a\n#b", []string{"a", "b"}},
+		{"blank line after heading", "**Tags:**\n\n#a #b", []string{"a", "b"}},
+		{"stops at text", "**Tags:**\n#a\nsome notes #b", []string{"a"}},
+		{"no section", "```shell\nls\n```", nil},
+		{"empty section", "text\n\n**Tags:**\n", nil},
+		{"hashtags outside section", "#a #b and then #c", nil},
+		{"shell comment in code", "```shell\n# list files #ls\nls\n```\n\n**Tags:**\n#cmd", []string{"cmd"}},
+		{"section inside code", "```markdown\n**Tags:**\n#fake\n```\n\n**Tags:**\n#real", []string{"real"}},
+		{"tilde fence", "~~~\n**Tags:** #fake\n~~~\n**Tags:** #real", []string{"real"}},
+		{"longer closing fence", "````\n```\n**Tags:** #fake\n````\n**Tags:** #real", []string{"real"}},
+		{"unterminated fence", "```shell\nls\n**Tags:** #fake", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractTags(tt.content); !slices.Equal(got, tt.want) {
+				t.Errorf("ExtractTags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		tags    []string
+		want    string
+	}{
+		{"replace", "```shell\nls\n```\n\n**Tags:**\n#cmd #ls", []string{"cmd", "files"}, "```shell\nls\n```\n\n**Tags:**\n#cmd #files"},
+		{"append", "```shell\nls\n```\n", []string{"cmd"}, "```shell\nls\n```\n\n**Tags:**\n#cmd"},
+		{"keeps trailing text", "**Tags:**\n#a\n\nmore", []string{"#b"}, "**Tags:**\n#b\n\nmore"},
+		{"same line heading", "x\n\n**Tags:** #a #b", []string{"c"}, "x\n\n**Tags:**\n#c"},
+		{"ignores code", "```\n**Tags:** #fake\n```", []string{"a"}, "```\n**Tags:** #fake\n```\n\n**Tags:**\n#a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReplaceTags(tt.content, tt.tags); got != tt.want {
+				t.Errorf("ReplaceTags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"cmd":       "cmd",
+		"#K8s":      "k8s",
+		" My Tag ":  "my-tag",
+		"c++":       "c",
+		"infra/aws": "infra/aws",
+		"ci-cd":     "ci-cd",
+		"tag!":      "tag",
+		"a & b, c":  "a-b-c",
+		"données":   "données",
+		"#":         "",
+		"!!":        "",
+	}
+	for tag, want := range tests {
+		if got := NormalizeTag(tag); got != want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
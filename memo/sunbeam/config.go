@@ -2,55 +2,283 @@ package sunbeam
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 )
 
-// Preferences holds the memo token and URL.
+// DefaultProfile is the extension alias used when no profile is chosen.
+const DefaultProfile = "memos"
+
+// Preferences holds the memo token and URL, and the default memo visibility.
 type Preferences struct {
-	MemoToken string `json:"memo_token"`
-	MemoURL   string `json:"memo_url"`
+	MemoToken      string `json:"memo_token"`
+	MemoURL        string `json:"memo_url"`
+	MemoVisibility string `json:"memo_visibility"`
+}
+
+// Oneliner is a shell command shown in the Sunbeam root list.
+type Oneliner struct {
+	Title   string `json:"title"`
+	Command string `json:"command"`
+	Cwd     string `json:"cwd,omitempty"`
+	Exit    bool   `json:"exit,omitempty"`
 }
 
-// MemoExtension holds the preferences for the memos extension.
-type MemoExtension struct {
-	Origin      string      `json:"origin"`
-	Preferences Preferences `json:"preferences"`
+// RootItem is an extension command shown in the Sunbeam root list.
+type RootItem struct {
+	Title   string         `json:"title"`
+	Command string         `json:"command"`
+	Params  map[string]any `json:"params,omitempty"`
 }
 
-// Extensions contains all extensions, including memos.
-type Extensions struct {
-	Memos MemoExtension `json:"memos"`
+// Extension is an installed extension: where it comes from, its
+// preferences and the commands it adds to the root list.
+type Extension struct {
+	Origin      string         `json:"origin"`
+	Preferences map[string]any `json:"preferences,omitempty"`
+	Items       []RootItem     `json:"items,omitempty"`
 }
 
-// SunbeamConfig represents the full Sunbeam configuration.
-type SunbeamConfig struct {
-	Extensions Extensions `json:"extensions"`
+// Config is the Sunbeam configuration file. Extensions are keyed by alias;
+// the same extension can be installed several times under different
+// aliases with different preferences, which is how memo profiles work:
+//
+//	"extensions": {
+//	  "memos-work":     {"origin": "...", "preferences": {"memo_url": "...", "memo_token": "..."}},
+//	  "memos-personal": {"origin": "...", "preferences": {"memo_url": "...", "memo_token": "..."}}
+//	}
+type Config struct {
+	Schema     string               `json:"$schema,omitempty"`
+	Oneliners  []Oneliner           `json:"oneliners,omitempty"`
+	Extensions map[string]Extension `json:"extensions,omitempty"`
+
+	// Path is the file the configuration was read from.
+	Path string `json:"-"`
 }
 
-// readSunbeamConfig reads the Sunbeam extensions configuration file and retrieves the memo token and URL.
-func ReadSunbeamConfig(configPath string) (Preferences, error) {
-	// Open the configuration file
-	file, err := os.Open(configPath)
+// ConfigPath returns the Sunbeam configuration file: $SUNBEAM_CONFIG, else
+// sunbeam/sunbeam.json in $XDG_CONFIG_HOME or ~/.config.
+func ConfigPath() string {
+	if path := os.Getenv("SUNBEAM_CONFIG"); path != "" {
+		return path
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(configHome, "sunbeam", "sunbeam.json")
+}
+
+// ReadConfig reads a Sunbeam configuration file. The error wraps
+// fs.ErrNotExist when the file does not exist.
+func ReadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return Preferences{}, fmt.Errorf("error opening configuration file: %v", err)
+		return nil, fmt.Errorf("error reading configuration file: %w", err)
+	}
+	config := &Config{Path: path}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// isMemoProfile reports whether the extension installed under alias is a
+// memo profile: it is named memos or memos-*, or sets memo preferences.
+func isMemoProfile(alias string, ext Extension) bool {
+	if alias == DefaultProfile || strings.HasPrefix(alias, DefaultProfile+"-") {
+		return true
+	}
+	_, hasURL := ext.Preferences["memo_url"]
+	_, hasToken := ext.Preferences["memo_token"]
+	return hasURL || hasToken
+}
+
+// Profiles returns the aliases of the memo profiles, sorted.
+func (c *Config) Profiles() []string {
+	var profiles []string
+	for alias, ext := range c.Extensions {
+		if isMemoProfile(alias, ext) {
+			profiles = append(profiles, alias)
+		}
 	}
-	defer file.Close()
+	slices.Sort(profiles)
+	return profiles
+}
 
-	// Read the file content
-	data, err := io.ReadAll(file)
+// Profile returns the preferences of a memo profile. name is an extension
+// alias, "work" also finds "memos-work". An empty name picks the memos
+// profile, or the only profile when there is just one.
+func (c *Config) Profile(name string) (string, Preferences, error) {
+	profiles := c.Profiles()
+	alias := ""
+	switch {
+	case name != "":
+		for _, candidate := range []string{name, DefaultProfile + "-" + name} {
+			if slices.Contains(profiles, candidate) {
+				alias = candidate
+				break
+			}
+		}
+		if alias == "" {
+			return "", Preferences{}, fmt.Errorf("no memos profile %q in %s; %s", name, c.Path, availableProfiles(profiles))
+		}
+	case slices.Contains(profiles, DefaultProfile):
+		alias = DefaultProfile
+	case len(profiles) == 1:
+		alias = profiles[0]
+	case len(profiles) > 1:
+		return "", Preferences{}, fmt.Errorf("%s has several memos profiles (%s): choose one with --profile or MEMO_PROFILE",
+			c.Path, strings.Join(profiles, ", "))
+	default:
+		return "", Preferences{}, nil
+	}
+
+	preferences, err := preferencesFrom(c.Extensions[alias].Preferences)
 	if err != nil {
-		return Preferences{}, fmt.Errorf("error reading configuration file: %v", err)
+		return "", Preferences{}, fmt.Errorf("profile %q of %s: %w", alias, c.Path, err)
 	}
+	return alias, preferences, nil
+}
+
+// preferencesFrom reads the memo preferences from the preference values of
+// an extension.
+func preferencesFrom(values map[string]any) (Preferences, error) {
+	var preferences Preferences
+	for key, target := range map[string]*string{
+		"memo_url":        &preferences.MemoURL,
+		"memo_token":      &preferences.MemoToken,
+		"memo_visibility": &preferences.MemoVisibility,
+	} {
+		value, ok := values[key]
+		if !ok || value == nil {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return Preferences{}, fmt.Errorf("%s must be a string", key)
+		}
+		*target = strings.TrimSpace(s)
+	}
+	return preferences, nil
+}
 
-	// Parse JSON content
-	var config SunbeamConfig
-	err = json.Unmarshal(data, &config)
+// availableProfiles describes the profiles a user can choose from.
+func availableProfiles(profiles []string) string {
+	if len(profiles) == 0 {
+		return `add an extension named "memos" or "memos-<profile>" with memo_url and memo_token preferences`
+	}
+	return "available profiles: " + strings.Join(profiles, ", ")
+}
+
+// Validate checks the URL and token, saying where to fix them. source
+// names where the values came from. Token references are not resolved.
+func (p Preferences) Validate(source string) error {
+	if p.MemoURL == "" || p.MemoToken == "" {
+		var missing []string
+		if p.MemoURL == "" {
+			missing = append(missing, "memo_url")
+		}
+		if p.MemoToken == "" {
+			missing = append(missing, "memo_token")
+		}
+		return fmt.Errorf("%s missing from %s: add to the preferences of the memos extension in the Sunbeam config, or set USEMEMOS_API_URL and USEMEMOS_API_KEY",
+			strings.Join(missing, " and "), source)
+	}
+
+	u, err := url.Parse(p.MemoURL)
+	switch {
+	case err != nil:
+		return fmt.Errorf("memo_url %q from %s is not a valid URL: %v", p.MemoURL, source, err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		_, host, found := strings.Cut(p.MemoURL, "://")
+		if !found {
+			host = p.MemoURL
+		}
+		return fmt.Errorf("memo_url %q from %s needs an http:// or https:// scheme, e.g. https://%s", p.MemoURL, source, host)
+	case u.Host == "":
+		return fmt.Errorf("memo_url %q from %s has no host, e.g. https://memos.example.com", p.MemoURL, source)
+	case u.RawQuery != "" || u.Fragment != "":
+		return fmt.Errorf("memo_url %q from %s should be the server address without query or fragment", p.MemoURL, source)
+	}
+
+	// References are checked when they are resolved
+	if IsTokenReference(p.MemoToken) {
+		return nil
+	}
+	if fields := strings.Fields(p.MemoToken); len(fields) > 1 {
+		if strings.EqualFold(fields[0], "bearer") {
+			return fmt.Errorf("memo_token from %s starts with %q: use the token alone, without the Bearer prefix", source, fields[0])
+		}
+		return fmt.Errorf("memo_token from %s contains spaces: copy the access token from Settings > My Account in Memos", source)
+	}
+	return nil
+}
+
+// LoadPreferences reads the memo preferences of a profile from the Sunbeam
+// configuration file (see ConfigPath). An empty profile falls back to
+// $MEMO_PROFILE, then to the default profile. Values missing from the
+// default profile, or a missing file, fall back to the USEMEMOS_API_KEY,
+// USEMEMOS_API_URL and USEMEMOS_VISIBILITY environment variables; a
+// profile chosen by name must be complete.
+func LoadPreferences(profile string) (Preferences, error) {
+	if profile == "" {
+		profile = os.Getenv("MEMO_PROFILE")
+	}
+
+	path := ConfigPath()
+	config, err := ReadConfig(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if profile != "" {
+			return Preferences{}, fmt.Errorf("profile %q requested but %s does not exist", profile, path)
+		}
+		config = &Config{Path: path}
+	case err != nil:
+		return Preferences{}, err
+	}
+
+	alias, preferences, err := config.Profile(profile)
 	if err != nil {
-		return Preferences{}, fmt.Errorf("error parsing JSON: %v", err)
+		return Preferences{}, err
+	}
+	source := fmt.Sprintf("the environment (no memos profile in %s)", path)
+	if alias != "" {
+		source = fmt.Sprintf("profile %q in %s", alias, path)
+	}
+	if profile != "" {
+		return preferences, preferences.Validate(source)
 	}
 
-	// Return preferences
-	return config.Extensions.Memos.Preferences, nil
+	if preferences.fillFromEnv() && alias != "" {
+		source += " and the environment"
+	}
+	return preferences, preferences.Validate(source)
+}
+
+// fillFromEnv sets the values missing from p from the USEMEMOS_API_KEY,
+// USEMEMOS_API_URL and USEMEMOS_VISIBILITY environment variables and
+// reports whether any was used.
+func (p *Preferences) fillFromEnv() bool {
+	fromEnv := false
+	for _, fallback := range []struct {
+		value *string
+		env   string
+	}{
+		{&p.MemoToken, "USEMEMOS_API_KEY"},
+		{&p.MemoURL, "USEMEMOS_API_URL"},
+		{&p.MemoVisibility, "USEMEMOS_VISIBILITY"},
+	} {
+		if *fallback.value == "" {
+			*fallback.value = os.Getenv(fallback.env)
+			fromEnv = fromEnv || *fallback.value != ""
+		}
+	}
+	return fromEnv
 }
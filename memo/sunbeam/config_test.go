@@ -0,0 +1,162 @@
+package sunbeam
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+  "$schema": "https://sunbeam.deno.dev/schemas/config.json",
+  "oneliners": [{"title": "Edit config", "command": "vim ~/.config/sunbeam/sunbeam.json"}],
+  "extensions": {
+    "github": {"origin": "https://example.com/github.sh", "preferences": {"token": "gh"}},
+    "memos-work": {"origin": "~/bin/memo", "preferences": {"memo_url": "https://memos.work.example", "memo_token": "work-token"}},
+    "personal": {"origin": "~/bin/memo", "preferences": {"memo_url": "https://memos.home.example", "memo_token": "home-token", "memo_visibility": "protected"},
+      "items": [{"title": "Search memos", "command": "list", "params": {"tags": "cmd"}}]}
+  }
+}`
+
+// writeConfig writes a Sunbeam config and points SUNBEAM_CONFIG at it.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sunbeam.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SUNBEAM_CONFIG", path)
+	return path
+}
+
+// clearEnv unsets the variables LoadPreferences reads.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"SUNBEAM_CONFIG", "XDG_CONFIG_HOME", "MEMO_PROFILE", "USEMEMOS_API_KEY", "USEMEMOS_API_URL", "USEMEMOS_VISIBILITY"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HOME", t.TempDir())
+}
+
+func TestConfigPath(t *testing.T) {
+	clearEnv(t)
+	home := os.Getenv("HOME")
+	if got, want := ConfigPath(), filepath.Join(home, ".config", "sunbeam", "sunbeam.json"); got != want {
+		t.Errorf("ConfigPath() = %q, want %q", got, want)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if got := ConfigPath(); got != "/xdg/sunbeam/sunbeam.json" {
+		t.Errorf("ConfigPath() with XDG_CONFIG_HOME = %q", got)
+	}
+	t.Setenv("SUNBEAM_CONFIG", "/etc/sunbeam.json")
+	if got := ConfigPath(); got != "/etc/sunbeam.json" {
+		t.Errorf("ConfigPath() with SUNBEAM_CONFIG = %q", got)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	clearEnv(t)
+	config, err := ReadConfig(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	if len(config.Oneliners) != 1 || config.Extensions["personal"].Items[0].Params["tags"] != "cmd" {
+		t.Errorf("config not fully decoded: %+v", config)
+	}
+	if got := strings.Join(config.Profiles(), ","); got != "memos-work,personal" {
+		t.Errorf("Profiles() = %s", got)
+	}
+}
+
+func TestLoadPreferencesProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		env     string
+		wantURL string
+		wantErr string
+		wantVis string
+	}{
+		{name: "by alias", profile: "personal", wantURL: "https://memos.home.example", wantVis: "protected"},
+		{name: "memos- prefix", profile: "work", wantURL: "https://memos.work.example"},
+		{name: "MEMO_PROFILE", env: "work", wantURL: "https://memos.work.example"},
+		{name: "unknown", profile: "nope", wantErr: "available profiles: memos-work, personal"},
+		{name: "ambiguous", wantErr: "several memos profiles (memos-work, personal)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			writeConfig(t, testConfig)
+			t.Setenv("MEMO_PROFILE", tt.env)
+			got, err := LoadPreferences(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadPreferences error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPreferences: %v", err)
+			}
+			if got.MemoURL != tt.wantURL || got.MemoVisibility != tt.wantVis {
+				t.Errorf("LoadPreferences = %+v, want URL %s visibility %q", got, tt.wantURL, tt.wantVis)
+			}
+		})
+	}
+}
+
+func TestLoadPreferencesEnvironment(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("USEMEMOS_API_URL", "https://memos.env.example")
+	t.Setenv("USEMEMOS_API_KEY", "env-token")
+
+	// No config file at all
+	got, err := LoadPreferences("")
+	if err != nil || got.MemoURL != "https://memos.env.example" || got.MemoToken != "env-token" {
+		t.Fatalf("LoadPreferences without config = %+v, %v", got, err)
+	}
+	if _, err := LoadPreferences("work"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("named profile without config: got %v", err)
+	}
+
+	// The default profile fills missing values from the environment
+	writeConfig(t, `{"extensions": {"memos": {"origin": "memo", "preferences": {"memo_url": "https://memos.file.example"}}}}`)
+	got, err = LoadPreferences("")
+	if err != nil || got.MemoURL != "https://memos.file.example" || got.MemoToken != "env-token" {
+		t.Errorf("LoadPreferences with partial profile = %+v, %v", got, err)
+	}
+	// A profile chosen by name must be complete
+	if _, err := LoadPreferences("memos"); err == nil || !strings.Contains(err.Error(), "memo_token missing") {
+		t.Errorf("incomplete named profile: got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		url, token string
+		wantErr    string
+	}{
+		{"https://memos.example.com", "abc.def.ghi", ""},
+		{"http://localhost:5230/", "abc", ""},
+		{"", "", "memo_url and memo_token missing"},
+		{"memos.example.com", "abc", "e.g. https://memos.example.com"},
+		{"localhost:5230", "abc", "e.g. https://localhost:5230"},
+		{"ftp://memos.example.com", "abc", "needs an http:// or https:// scheme"},
+		{"https://", "abc", "has no host"},
+		{"https://memos.example.com/?x=1", "abc", "without query"},
+		{"https://memos.example.com", "Bearer abc", "without the Bearer prefix"},
+		{"https://memos.example.com", "abc def", "contains spaces"},
+	}
+	for _, tt := range tests {
+		err := Preferences{MemoURL: tt.url, MemoToken: tt.token}.Validate("test")
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%q, %q) = %v", tt.url, tt.token, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%q, %q) = %v, want %q", tt.url, tt.token, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package sunbeam
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Command modes: filter and detail commands print a page, silent commands
+// run in the background and tty commands take over the terminal.
+const (
+	ModeFilter = "filter"
+	ModeDetail = "detail"
+	ModeSilent = "silent"
+	ModeTTY    = "tty"
+)
+
+// Input types of preferences and command parameters.
+const (
+	InputString  = "string"
+	InputBoolean = "boolean"
+	InputNumber  = "number"
+)
+
+// Input is a preference or a command parameter.
+type Input struct {
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Type     string `json:"type"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// Command is an extension command. Sunbeam runs the extension with a
+// Payload naming it.
+type Command struct {
+	Name   string  `json:"name"`
+	Title  string  `json:"title"`
+	Mode   string  `json:"mode"`
+	Hidden bool    `json:"hidden,omitempty"`
+	Params []Input `json:"params,omitempty"`
+}
+
+// Manifest describes an extension. Sunbeam runs the extension without
+// arguments to read it.
+type Manifest struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Preferences []Input   `json:"preferences,omitempty"`
+	Commands    []Command `json:"commands"`
+}
+
+// PreferenceInputs are the preference definitions of the memos extension.
+var PreferenceInputs = []Input{
+	{Name: "memo_url", Title: "Memos URL", Type: InputString},
+	{Name: "memo_token", Title: "Access Token or keyring:, cmd: or file: reference", Type: InputString},
+	{Name: "memo_visibility", Title: "Default Visibility (private, protected or public)", Type: InputString, Optional: true},
+}
+
+// Payload is the JSON argument Sunbeam runs an extension command with.
+type Payload struct {
+	Command     string         `json:"command"`
+	Preferences map[string]any `json:"preferences"`
+	Params      map[string]any `json:"params"`
+	Cwd         string         `json:"cwd"`
+	Query       string         `json:"query"`
+}
+
+// ParsePayload parses the argument of an extension command invocation.
+func ParsePayload(arg string) (Payload, error) {
+	var payload Payload
+	if err := json.Unmarshal([]byte(arg), &payload); err != nil {
+		return Payload{}, fmt.Errorf("invalid Sunbeam payload: %w", err)
+	}
+	if payload.Command == "" {
+		return Payload{}, fmt.Errorf("invalid Sunbeam payload: no command")
+	}
+	return payload, nil
+}
+
+// MemoPreferences returns the memo preferences Sunbeam passed. Missing
+// values fall back to the USEMEMOS_API_KEY, USEMEMOS_API_URL and
+// USEMEMOS_VISIBILITY environment variables.
+func (p Payload) MemoPreferences() (Preferences, error) {
+	source := "the Sunbeam extension preferences"
+	preferences, err := preferencesFrom(p.Preferences)
+	if err != nil {
+		return Preferences{}, fmt.Errorf("%s: %w", source, err)
+	}
+	if preferences.fillFromEnv() {
+		source += " and the environment"
+	}
+	return preferences, preferences.Validate(source)
+}
+
+// String returns a string parameter, or "" when it is not set.
+func (p Payload) String(name string) (string, error) {
+	switch v := p.Params[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	}
+	return "", fmt.Errorf("parameter %s of %s must be a string", name, p.Command)
+}
+
+// Bool returns a boolean parameter, or false when it is not set.
+func (p Payload) Bool(name string) (bool, error) {
+	switch v := p.Params[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, fmt.Errorf("parameter %s of %s must be a boolean", name, p.Command)
+}
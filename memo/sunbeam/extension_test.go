@@ -0,0 +1,88 @@
+package sunbeam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePayload(t *testing.T) {
+	payload, err := ParsePayload(`{"command": "list", "preferences": {"memo_url": "https://memos.example", "memo_token": "abc"},
+		"params": {"tags": " k8s ", "run": true}, "cwd": "/tmp"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Command != "list" || payload.Cwd != "/tmp" {
+		t.Errorf("ParsePayload() = %+v", payload)
+	}
+	if tags, err := payload.String("tags"); err != nil || tags != "k8s" {
+		t.Errorf("String(tags) = %q, %v", tags, err)
+	}
+	if run, err := payload.Bool("run"); err != nil || !run {
+		t.Errorf("Bool(run) = %v, %v", run, err)
+	}
+	if missing, err := payload.String("query"); err != nil || missing != "" {
+		t.Errorf("String(query) = %q, %v", missing, err)
+	}
+	if _, err := payload.Bool("tags"); err == nil {
+		t.Error("Bool(tags) of a string parameter succeeded")
+	}
+
+	for _, arg := range []string{`{"params": {}}`, `{"command": `, `[]`} {
+		if _, err := ParsePayload(arg); err == nil {
+			t.Errorf("ParsePayload(%s) succeeded", arg)
+		}
+	}
+}
+
+func TestPayloadMemoPreferences(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences map[string]any
+		env         map[string]string
+		want        Preferences
+		wantErr     string
+	}{
+		{
+			name:        "from sunbeam",
+			preferences: map[string]any{"memo_url": "https://memos.example", "memo_token": "abc", "memo_visibility": "public"},
+			want:        Preferences{MemoURL: "https://memos.example", MemoToken: "abc", MemoVisibility: "public"},
+		},
+		{
+			name:        "environment fills gaps",
+			preferences: map[string]any{"memo_url": "https://memos.example"},
+			env:         map[string]string{"USEMEMOS_API_KEY": "env-token"},
+			want:        Preferences{MemoURL: "https://memos.example", MemoToken: "env-token"},
+		},
+		{
+			name:        "missing token",
+			preferences: map[string]any{"memo_url": "https://memos.example"},
+			wantErr:     "memo_token missing from the Sunbeam extension preferences",
+		},
+		{
+			name:        "wrong type",
+			preferences: map[string]any{"memo_url": 5230},
+			wantErr:     "memo_url must be a string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			got, err := Payload{Command: "list", Preferences: tt.preferences}.MemoPreferences()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MemoPreferences() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("MemoPreferences() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package sunbeam
+
+// Action types understood by Sunbeam.
+const (
+	ActionCopy   = "copy"
+	ActionOpen   = "open"
+	ActionExec   = "exec"
+	ActionRun    = "run"
+	ActionReload = "reload"
+)
+
+// Action is something the user can do with a list item.
+type Action struct {
+	Title string `json:"title,omitempty"`
+	Type  string `json:"type"`
+	// Text is copied by copy actions.
+	Text string `json:"text,omitempty"`
+	// URL is opened by open actions.
+	URL string `json:"url,omitempty"`
+	// Command is the shell command of exec actions, or the extension
+	// command of run actions.
+	Command string `json:"command,omitempty"`
+	// Params are passed to the extension command of run actions.
+	Params map[string]any `json:"params,omitempty"`
+	// Exit closes Sunbeam once the action is done.
+	Exit bool `json:"exit,omitempty"`
+	// Reload refreshes the current page once the action is done.
+	Reload bool `json:"reload,omitempty"`
+}
+
+// ListItem is one row of a list page.
+type ListItem struct {
+	Title       string   `json:"title"`
+	Subtitle    string   `json:"subtitle,omitempty"`
+	Accessories []string `json:"accessories,omitempty"`
+	Actions     []Action `json:"actions,omitempty"`
+}
+
+// List is a Sunbeam list page.
+type List struct {
+	Type        string     `json:"type"`
+	EmptyText   string     `json:"emptyText,omitempty"`
+	ShowDetails bool       `json:"showDetails,omitempty"`
+	Items       []ListItem `json:"items"`
+}
+
+// NewList returns a list page holding items.
+func NewList(items ...ListItem) List {
+	if items == nil {
+		items = []ListItem{}
+	}
+	return List{Type: "list", Items: items}
+}
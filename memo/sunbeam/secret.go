@@ -0,0 +1,165 @@
+package sunbeam
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Token reference prefixes. A memo_token starting with one of them names
+// where the token is stored instead of holding it:
+//
+//	keyring:memos/work       the OS keyring, service memos, account work
+//	cmd:pass show memos      the output of a shell command
+//	file:~/.secrets/memos    the content of a file
+const (
+	keyringPrefix = "keyring:"
+	cmdPrefix     = "cmd:"
+	filePrefix    = "file:"
+)
+
+// Keyring reads secrets from a keyring.
+type Keyring interface {
+	Get(service, account string) (string, error)
+}
+
+// keyring resolves keyring: references. Tests replace it with a fake so
+// they do not need a desktop session.
+var keyring Keyring = systemKeyring{}
+
+// systemKeyring reads the OS keyring through its command-line tool:
+// security on macOS and secret-tool (libsecret) elsewhere.
+type systemKeyring struct{}
+
+// keyringCommand returns the command looking up a secret on goos.
+func keyringCommand(goos, service, account string) []string {
+	if goos == "darwin" {
+		args := []string{"security", "find-generic-password", "-s", service, "-w"}
+		if account != "" {
+			args = append(args, "-a", account)
+		}
+		return args
+	}
+	args := []string{"secret-tool", "lookup", "service", service}
+	if account != "" {
+		args = append(args, "account", account)
+	}
+	return args
+}
+
+func (systemKeyring) Get(service, account string) (string, error) {
+	args := keyringCommand(runtime.GOOS, service, account)
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("%s not found: install it or use a cmd: or file: token reference", args[0])
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", args[0], err, msg)
+		}
+		// secret-tool exits 1 without output when there is no such secret
+		return "", fmt.Errorf("no secret for service %q account %q in the keyring, store it with: %s",
+			service, account, storeHint(service, account))
+	}
+	return string(out), nil
+}
+
+// storeHint is the command storing a token in the keyring.
+func storeHint(service, account string) string {
+	if runtime.GOOS == "darwin" {
+		if account == "" {
+			account = os.Getenv("USER")
+		}
+		return fmt.Sprintf("security add-generic-password -s %s -a %s -w", service, account)
+	}
+	hint := "secret-tool store --label=memos service " + service
+	if account != "" {
+		hint += " account " + account
+	}
+	return hint
+}
+
+// IsTokenReference reports whether token names where the token is stored
+// rather than being the token itself.
+func IsTokenReference(token string) bool {
+	for _, prefix := range []string{keyringPrefix, cmdPrefix, filePrefix} {
+		if strings.HasPrefix(token, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome expands a leading ~ and environment variables in path.
+func expandHome(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	return path
+}
+
+// ResolveToken returns the token a memo_token value stands for: the value
+// itself, or the secret it references.
+func ResolveToken(token string) (string, error) {
+	var secret string
+	switch {
+	case strings.HasPrefix(token, keyringPrefix):
+		ref := strings.TrimPrefix(token, keyringPrefix)
+		service, account, _ := strings.Cut(ref, "/")
+		if service == "" {
+			return "", fmt.Errorf("token reference %q names no keyring service, e.g. keyring:memos/work", token)
+		}
+		s, err := keyring.Get(service, account)
+		if err != nil {
+			return "", err
+		}
+		secret = s
+	case strings.HasPrefix(token, cmdPrefix):
+		command := strings.TrimSpace(strings.TrimPrefix(token, cmdPrefix))
+		if command == "" {
+			return "", fmt.Errorf("token reference %q has no command, e.g. cmd:pass show memos", token)
+		}
+		cmd := exec.Command("/bin/sh", "-c", command)
+		// Password managers may prompt on the terminal
+		cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("token command %q failed: %w", command, err)
+		}
+		// pass and similar tools print the secret on the first line
+		secret, _, _ = strings.Cut(string(out), "\n")
+	case strings.HasPrefix(token, filePrefix):
+		path := expandHome(strings.TrimPrefix(token, filePrefix))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading token file: %w", err)
+		}
+		secret = string(data)
+	default:
+		return token, nil
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("token reference %q resolved to an empty token", token)
+	}
+	if strings.ContainsAny(secret, " \t\n") {
+		return "", fmt.Errorf("token reference %q resolved to text with spaces, not a single token", token)
+	}
+	return secret, nil
+}
+
+// ResolveToken returns the memo token, resolving a token reference. Pass it
+// as the client's TokenSource so secrets are only read when needed.
+func (p Preferences) ResolveToken() (string, error) {
+	return ResolveToken(p.MemoToken)
+}
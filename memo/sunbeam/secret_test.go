@@ -0,0 +1,103 @@
+package sunbeam
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeKeyring is an in-memory keyring keyed by "service/account".
+type fakeKeyring map[string]string
+
+func (k fakeKeyring) Get(service, account string) (string, error) {
+	secret, ok := k[service+"/"+account]
+	if !ok {
+		return "", errors.New("no such secret")
+	}
+	return secret, nil
+}
+
+func useFakeKeyring(t *testing.T, k fakeKeyring) {
+	t.Helper()
+	saved := keyring
+	keyring = k
+	t.Cleanup(func() { keyring = saved })
+}
+
+func TestResolveToken(t *testing.T) {
+	useFakeKeyring(t, fakeKeyring{"memos/work": "work-token\n", "memos/": "default-token"})
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "memos-token"), []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "empty"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token   string
+		want    string
+		wantErr string
+	}{
+		{"literal-token", "literal-token", ""},
+		{"keyring:memos/work", "work-token", ""},
+		{"keyring:memos", "default-token", ""},
+		{"keyring:memos/home", "", "no such secret"},
+		{"keyring:", "", "names no keyring service"},
+		{"cmd:printf 'cmd-token\\nlogin: me\\n'", "cmd-token", ""},
+		{"cmd:exit 3", "", "failed"},
+		{"cmd:", "", "has no command"},
+		{"file:~/memos-token", "file-token", ""},
+		{"file:$HOME/memos-token", "file-token", ""},
+		{"file:~/missing", "", "reading token file"},
+		{"file:~/empty", "", "empty token"},
+		{"cmd:echo two words", "", "with spaces"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveToken(tt.token)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveToken(%q) error = %v, want %q", tt.token, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveToken(%q) = %q, %v, want %q", tt.token, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadPreferencesKeepsReferences(t *testing.T) {
+	// Loading must not touch the keyring; the token is resolved on first use
+	useFakeKeyring(t, fakeKeyring{})
+	clearEnv(t)
+	writeConfig(t, `{"extensions": {"memos": {"origin": "memo", "preferences": {"memo_url": "https://memos.example.com", "memo_token": "cmd:pass show memos"}}}}`)
+
+	got, err := LoadPreferences("")
+	if err != nil {
+		t.Fatalf("LoadPreferences: %v", err)
+	}
+	if got.MemoToken != "cmd:pass show memos" {
+		t.Errorf("MemoToken = %q, want the unresolved reference", got.MemoToken)
+	}
+}
+
+func TestKeyringCommand(t *testing.T) {
+	tests := []struct {
+		goos, service, account string
+		want                   []string
+	}{
+		{"linux", "memos", "work", []string{"secret-tool", "lookup", "service", "memos", "account", "work"}},
+		{"linux", "memos", "", []string{"secret-tool", "lookup", "service", "memos"}},
+		{"darwin", "memos", "work", []string{"security", "find-generic-password", "-s", "memos", "-w", "-a", "work"}},
+	}
+	for _, tt := range tests {
+		if got := keyringCommand(tt.goos, tt.service, tt.account); !slices.Equal(got, tt.want) {
+			t.Errorf("keyringCommand(%s, %s, %s) = %q, want %q", tt.goos, tt.service, tt.account, got, tt.want)
+		}
+	}
+}